@@ -211,23 +211,29 @@ func incBodyV2(req *http.Request) bool {
 
 // VerifyCallback 验证上传回调请求是否来自七牛
 func (ath *Credentials) VerifyCallback(req *http.Request) (bool, error) {
-	auth := req.Header.Get("Authorization")
-	if auth == "" {
-		return false, nil
-	}
+	_, ok, err := ath.VerifyCallbackWithType(req)
+	return ok, err
+}
 
-	if strings.HasPrefix(auth, AuthorizationPrefixQiniu) {
-		token, err := ath.SignRequestV2(req)
-		if err != nil {
-			return false, err
+// VerifyCallbackWithType 验证上传回调请求是否来自七牛，根据 Authorization 的前缀自动识别签名格式：
+// "Qiniu " 为新的签名格式（TokenQiniu），"QBox " 为旧的签名格式（TokenQBox），并返回所使用的签名格式。
+// 没有 Authorization 或者前缀无法识别时返回 false
+func (ath *Credentials) VerifyCallbackWithType(req *http.Request) (tokenType TokenType, ok bool, err error) {
+	auth := req.Header.Get("Authorization")
+	var token string
+	switch {
+	case strings.HasPrefix(auth, AuthorizationPrefixQiniu):
+		tokenType = TokenQiniu
+		if token, err = ath.SignRequestV2(req); err != nil {
+			return
 		}
-		return auth == AuthorizationPrefixQiniu+token, nil
-	} else {
-
-		token, err := ath.SignRequest(req)
-		if err != nil {
-			return false, err
+		ok = auth == AuthorizationPrefixQiniu+token
+	case strings.HasPrefix(auth, AuthorizationPrefixQBox):
+		tokenType = TokenQBox
+		if token, err = ath.SignRequest(req); err != nil {
+			return
 		}
-		return auth == AuthorizationPrefixQBox+token, nil
+		ok = auth == AuthorizationPrefixQBox+token
 	}
+	return
 }
@@ -266,3 +266,34 @@ func TestCredentials_VerifyCallback(t *testing.T) {
 		}
 	}
 }
+
+func TestCredentials_VerifyCallbackWithType(t *testing.T) {
+	inputs := []ReqParams{
+		{Method: "", Url: "", Headers: http.Header{"Authorization": []string{"QBox ak:qfWnqF1E_vfzjZnReCVkcSMl29M="}}},
+		{Method: "", Url: "", Headers: http.Header{"Authorization": []string{"Qiniu ak:K1DI0goT05yhGizDFE5FiPJxAj4="}, "Content-Type": []string{"application/json"}}, Body: strings.NewReader(`{"name": "test"}`)},
+		{Method: "", Url: "", Headers: http.Header{"Authorization": []string{"Bearer ak:qfWnqF1E_vfzjZnReCVkcSMl29M="}}},
+		{Method: "", Url: "", Headers: http.Header{"Authorization": []string{"QBox ak:invalid"}}},
+	}
+	reqs, gErr := genRequests(inputs)
+	if gErr != nil {
+		t.Fatalf("generate requests: %v\n", gErr)
+	}
+
+	wants := []struct {
+		tokenType TokenType
+		ok        bool
+	}{
+		{TokenQBox, true},
+		{TokenQiniu, true},
+		// 无法识别的签名格式返回 TokenType 的零值
+		{TokenType(0), false},
+		// 签名不正确时仍然返回识别出的签名格式
+		{TokenQBox, false},
+	}
+	for ind, req := range reqs {
+		tokenType, ok, err := at.VerifyCallbackWithType(req)
+		if err != nil || ok != wants[ind].ok || tokenType != wants[ind].tokenType {
+			t.Errorf("index:%d tokenType:%v ok:%v err:%v\n", ind, tokenType, ok, err)
+		}
+	}
+}
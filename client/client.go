@@ -92,6 +92,11 @@ func newRequest(ctx context.Context, method, reqUrl string, headers http.Header,
 	return
 }
 
+// NewRequest 构造与 DoRequest 等方法相同的请求但不发送：添加默认的请求头，ctx 通过 auth.WithCredentialsType 设置了凭证时同时添加签名
+func NewRequest(ctx context.Context, method, reqUrl string, headers http.Header, body io.Reader) (req *http.Request, err error) {
+	return newRequest(ctx, method, reqUrl, headers, body)
+}
+
 func (r Client) DoRequest(ctx context.Context, method, reqUrl string, headers http.Header) (resp *http.Response, err error) {
 	req, err := newRequest(ctx, method, reqUrl, headers, nil)
 	if err != nil {
@@ -184,6 +189,28 @@ func (r Client) Do(ctx context.Context, req *http.Request) (resp *http.Response,
 	}
 
 	resp, err = r.Client.Do(req)
+	if err == nil {
+		if header, ok := responseHeaderFromContext(ctx, reqctx); ok {
+			*header = resp.Header
+		}
+	}
+	return
+}
+
+type responseHeaderKey struct{}
+
+// WithResponseHeader 返回一个新的 ctx，使用该 ctx 发送请求时，响应头会被保存到 header 中，
+// 同一个 ctx 发送多个请求时保存的是最后一个响应的响应头
+func WithResponseHeader(ctx context.Context, header *http.Header) context.Context {
+	return context.WithValue(ctx, responseHeaderKey{}, header)
+}
+
+func responseHeaderFromContext(ctxs ...context.Context) (header *http.Header, ok bool) {
+	for _, ctx := range ctxs {
+		if header, ok = ctx.Value(responseHeaderKey{}).(*http.Header); ok {
+			return
+		}
+	}
 	return
 }
 
@@ -195,6 +222,12 @@ type ErrorInfo struct {
 	Reqid string `json:"reqid,omitempty"`
 	Errno int    `json:"errno,omitempty"`
 	Code  int    `json:"code"`
+
+	// ErrorCode uc 接口返回的错误码，如 {"error_code":"BadRequest","error":"..."}，其他接口为空
+	ErrorCode string `json:"error_code,omitempty"`
+
+	// retryAfter 响应的 Retry-After 头，通过 RetryAfter 读取
+	retryAfter string
 }
 
 func (r *ErrorInfo) ErrorDetail() string {
@@ -218,6 +251,12 @@ func (r *ErrorInfo) HttpCode() int {
 	return r.Code
 }
 
+// RetryAfter 返回响应的 Retry-After 头，服务端限流时表示重试前需要等待的秒数或者时间点，没有该头时为空
+func (r *ErrorInfo) RetryAfter() string {
+
+	return r.retryAfter
+}
+
 // --------------------------------------------------------------------
 
 func parseError(e *ErrorInfo, r io.Reader) {
@@ -229,23 +268,43 @@ func parseError(e *ErrorInfo, r io.Reader) {
 	}
 
 	var ret struct {
-		Err   string `json:"error"`
-		Key   string `json:"key"`
-		Errno int    `json:"errno"`
+		Err       string          `json:"error"`
+		Key       string          `json:"key"`
+		Errno     int             `json:"errno"`
+		ErrorCode json.RawMessage `json:"error_code"`
 	}
-	if decodeJsonFromData(body, &ret) == nil && ret.Err != "" {
-		// qiniu error msg style returns here
-		e.Err, e.Key, e.Errno = ret.Err, ret.Key, ret.Errno
-		return
+	if decodeJsonFromData(body, &ret) == nil {
+		errorCode := parseErrorCode(ret.ErrorCode)
+		if ret.Err != "" || errorCode != "" {
+			// qiniu error msg style returns here, uc error envelope carries error_code as well
+			e.Err, e.Key, e.Errno, e.ErrorCode = ret.Err, ret.Key, ret.Errno, errorCode
+			if e.Err == "" {
+				e.Err = errorCode
+			}
+			return
+		}
 	}
 	e.Err = string(body)
 }
 
+// parseErrorCode uc 接口的 error_code 可能是字符串也可能是数字，统一转换为字符串
+func parseErrorCode(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var code string
+	if json.Unmarshal(raw, &code) == nil {
+		return code
+	}
+	return string(raw)
+}
+
 func ResponseError(resp *http.Response) (err error) {
 
 	e := &ErrorInfo{
-		Reqid: resp.Header.Get("X-Reqid"),
-		Code:  resp.StatusCode,
+		Reqid:      resp.Header.Get("X-Reqid"),
+		Code:       resp.StatusCode,
+		retryAfter: resp.Header.Get("Retry-After"),
 	}
 	if resp.StatusCode > 299 {
 		if resp.ContentLength != 0 {
@@ -0,0 +1,27 @@
+// +build unit
+
+package client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestResponseErrorRetryAfter(t *testing.T) {
+	resp := &http.Response{
+		StatusCode:    http.StatusTooManyRequests,
+		Header:        http.Header{"Content-Type": {"application/json"}, "Retry-After": {"3"}, "X-Reqid": {"reqid"}},
+		Body:          ioutil.NopCloser(strings.NewReader(`{"error":"too many requests"}`)),
+		ContentLength: -1,
+	}
+	e, ok := ResponseError(resp).(*ErrorInfo)
+	if !ok || e.RetryAfter() != "3" || e.Reqid != "reqid" || e.Err != "too many requests" {
+		t.Fatalf("unexpected error: %#v", e)
+	}
+	// ErrorInfo 需要保持可比较
+	if *e == (ErrorInfo{}) {
+		t.Fatal("error info should not be empty")
+	}
+}
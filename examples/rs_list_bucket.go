@@ -35,6 +35,10 @@ func main() {
 		os.Exit(1)
 	}
 	for listItem := range entries {
+		if listItem.Err != nil {
+			fmt.Fprintf(os.Stderr, "ListBucket: %v\n", listItem.Err)
+			os.Exit(1)
+		}
 		fmt.Println(listItem.Marker)
 		fmt.Println(listItem.Item)
 		fmt.Println(listItem.Dir)
@@ -0,0 +1,378 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// batchLimit 单次批量操作的最大操作数量
+const batchLimit = 1000
+
+// BucketKey 表示空间中的一个文件
+type BucketKey struct {
+	Bucket string
+	Key    string
+}
+
+// batchStatRet 批量查询文件信息的单个结果
+type batchStatRet struct {
+	Code int `json:"code"`
+	Data struct {
+		FileInfo
+		Error string `json:"error"`
+	} `json:"data"`
+}
+
+// BatchStat 批量查询空间中的文件信息，infos 和 errs 与 keys 一一对应，成功时 errs 中为 nil，
+// 查询失败时为包含空间和文件名的 *EntryError，其中的错误参见 batchOpError，如文件不存在时为 ErrNoSuchEntry。
+// 超过 1000 个文件时会分多次请求，请求失败时返回 err
+func (m *BucketManager) BatchStat(ctx context.Context, bucket string, keys []string) (infos []FileInfo, errs []error, err error) {
+	entries := make([]BucketKey, len(keys))
+	for i, key := range keys {
+		entries[i] = BucketKey{Bucket: bucket, Key: key}
+	}
+	infos, errs, err = m.batchStat(ctx, entries)
+	for i, e := range errs {
+		if errInfo, ok := e.(*ErrorInfo); ok {
+			errs[i] = &EntryError{Bucket: bucket, Key: keys[i], Err: batchOpError(errInfo.Code, errInfo.Err)}
+		}
+	}
+	return
+}
+
+// BatchStatSmart 与 BatchStat 相同，但会为 Fsize 大于 partsThreshold 的文件再单独查询一次分片信息，
+// 只有这些文件的 FileInfo.Parts 会被填充，适用于大量小文件中只有少数大文件可能是分片上传的场景。
+// 查询分片信息失败时，errs 中对应的位置为 *EntryError，infos 中保留第一次查询的结果；网络错误或者 ctx 取消时返回 err
+func (m *BucketManager) BatchStatSmart(ctx context.Context, bucket string, keys []string, partsThreshold int64) (infos []FileInfo, errs []error, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if infos, errs, err = m.BatchStat(ctx, bucket, keys); err != nil {
+		return
+	}
+	for i, info := range infos {
+		if errs[i] != nil || info.Fsize <= partsThreshold {
+			continue
+		}
+		var full FileInfo
+		sErr := m.entryCall(ctx, HostCategoryRs, &full, URIStat(bucket, keys[i])+"?needparts=true", BucketKey{bucket, keys[i]})
+		switch e := sErr.(type) {
+		case nil:
+			infos[i] = full
+		case *ErrorInfo:
+			errs[i] = &EntryError{Bucket: bucket, Key: keys[i], Err: batchOpError(e.Code, e.Err)}
+		case *EntryError:
+			errs[i] = e
+		default:
+			err = sErr
+			return
+		}
+	}
+	return
+}
+
+// BatchStatMulti 查询多个空间中的文件信息，成功的结果保存在 infos 中，单个文件查询失败的错误保存在 errs 中，
+// 错误与 BatchStat 相同为包含空间和文件名的 *EntryError，其中的错误参见 batchOpError，如文件不存在时为 ErrNoSuchEntry。
+// 超过 1000 个文件时会分多次请求，请求失败时返回 err 以及已经完成的结果。
+//
+// 请求发送到 Config.CentralRsHost，中心机房的 rs 服务可以处理公有云所有区域的空间，因此不同区域的空间可以在一次调用中查询；
+// 私有云需要将 CentralRsHost 设置为可以访问所有空间的 rs 服务。重复的文件只会查询一次
+func (m *BucketManager) BatchStatMulti(entries []BucketKey) (infos map[BucketKey]FileInfo, errs map[BucketKey]error, err error) {
+	unique := make([]BucketKey, 0, len(entries))
+	seen := make(map[BucketKey]struct{}, len(entries))
+	for _, e := range entries {
+		if _, ok := seen[e]; !ok {
+			seen[e] = struct{}{}
+			unique = append(unique, e)
+		}
+	}
+
+	infoList, errList, err := m.batchStat(context.Background(), unique)
+	infos = make(map[BucketKey]FileInfo, len(unique))
+	errs = make(map[BucketKey]error)
+	for i := range infoList {
+		if errInfo, ok := errList[i].(*ErrorInfo); ok {
+			errs[unique[i]] = &EntryError{Bucket: unique[i].Bucket, Key: unique[i].Key, Err: batchOpError(errInfo.Code, errInfo.Err)}
+		} else {
+			infos[unique[i]] = infoList[i]
+		}
+	}
+	return
+}
+
+// batchStat 分批查询文件信息，返回的结果与 entries 一一对应，请求失败时只返回已经完成的结果
+func (m *BucketManager) batchStat(ctx context.Context, entries []BucketKey) (infos []FileInfo, errs []error, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	infos = make([]FileInfo, 0, len(entries))
+	errs = make([]error, 0, len(entries))
+	for start := 0; start < len(entries); start += batchLimit {
+		end := start + batchLimit
+		if end > len(entries) {
+			end = len(entries)
+		}
+		chunk := entries[start:end]
+		ops := make([]string, len(chunk))
+		for i, e := range chunk {
+			ops[i] = URIStat(e.Bucket, e.Key)
+		}
+
+		var rets []batchStatRet
+		if err = m.batch(ctx, ops, &rets); err != nil {
+			return
+		}
+		if len(rets) != len(chunk) {
+			err = fmt.Errorf("batch stat returned %d results for %d operations", len(rets), len(chunk))
+			return
+		}
+		for _, ret := range rets {
+			if ret.Code == 200 {
+				infos = append(infos, ret.Data.FileInfo)
+				errs = append(errs, nil)
+			} else {
+				infos = append(infos, FileInfo{})
+				errs = append(errs, &ErrorInfo{Code: ret.Code, Err: ret.Data.Error})
+			}
+		}
+	}
+	return
+}
+
+// BatchFromChan 从 ops 中读取批量操作，每 1000 个（或 ops 关闭时剩余的）操作作为一批发送，最多同时发送 concurrency 批，
+// 并通过返回的 channel 按照操作读取的顺序返回每个操作的结果，所有结果返回后 channel 会被关闭。
+// 某一批请求失败时，该批中每个操作的结果的 Data.Error 为错误信息，Code 为错误码（不是 *ErrorInfo 时为 0）。
+// ctx 取消后停止读取 ops 并关闭返回的 channel，尚未返回的结果会被丢弃。
+// concurrency 为同时发送的最大批数，某一批遇到限流（429 或 573）时同时发送的批数减半，之后随着请求成功逐渐恢复，
+// 并发数变化时会调用 Config.OnBatchConcurrency；遇到限流的批不会重新发送，需要重试时请设置 Config.RetryPolicy
+func (m *BucketManager) BatchFromChan(ctx context.Context, ops <-chan string, concurrency int) (<-chan BatchOpRet, error) {
+	if ops == nil {
+		return nil, errors.New("ops channel is nil")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	results := make(chan BatchOpRet)
+	// 按照发送的顺序保存每一批的结果，缓冲区大小限制了同时发送的批数
+	pending := make(chan chan []BatchOpRet, concurrency-1)
+	limiter := newBatchLimiter(concurrency, m.Cfg.OnBatchConcurrency)
+
+	go func() {
+		defer close(pending)
+		dispatch := func(chunk []string) bool {
+			done := make(chan []BatchOpRet, 1)
+			select {
+			case pending <- done:
+			case <-ctx.Done():
+				return false
+			}
+			if !limiter.acquire(ctx) {
+				return false
+			}
+			go func() {
+				rets, err := m.batchChunk(ctx, chunk)
+				limiter.release(isThrottled(err))
+				done <- rets
+			}()
+			return true
+		}
+
+		chunk := make([]string, 0, batchLimit)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case op, ok := <-ops:
+				if !ok {
+					if len(chunk) > 0 {
+						dispatch(chunk)
+					}
+					return
+				}
+				chunk = append(chunk, op)
+				if len(chunk) == batchLimit {
+					if !dispatch(chunk) {
+						return
+					}
+					chunk = make([]string, 0, batchLimit)
+				}
+			}
+		}
+	}()
+
+	go func() {
+		defer close(results)
+		for done := range pending {
+			var rets []BatchOpRet
+			select {
+			case rets = <-done:
+			case <-ctx.Done():
+				return
+			}
+			for _, ret := range rets {
+				select {
+				case results <- ret:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return results, nil
+}
+
+// BatchAll 与 Batch 相同，但操作数量不受 1000 个的限制：每 1000 个操作作为一批，最多同时发送 concurrency 批。
+// 无论每一批完成的先后顺序如何，返回的 rets 总是与 ops 一一对应，某一批请求失败时的结果参见 BatchFromChan，
+// ctx 取消时返回 ctx.Err() 以及取消前已经返回的结果，这些结果与 ops 的前 len(rets) 个操作一一对应
+func (m *BucketManager) BatchAll(ctx context.Context, ops []string, concurrency int) (rets []BatchOpRet, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	opCh := make(chan string)
+	go func() {
+		defer close(opCh)
+		for _, op := range ops {
+			select {
+			case opCh <- op:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	results, err := m.BatchFromChan(ctx, opCh, concurrency)
+	if err != nil {
+		return
+	}
+	rets = make([]BatchOpRet, 0, len(ops))
+	for ret := range results {
+		rets = append(rets, ret)
+	}
+	err = ctx.Err()
+	return
+}
+
+// BatchWhere 遍历空间中以 prefix 开头的文件，对每个文件调用 predicate 生成批量操作（如 URIDelete、URIChangeType 的返回值），
+// keep 为 false 时跳过该文件。遍历的同时通过 BatchFromChan 发送操作，最多同时发送 concurrency 批，返回的 rets 与保留的操作一一对应。
+// predicate 在同一个 goroutine 中按照文件名的顺序调用。操作生成的新文件如果也以 prefix 开头可能会被再次遍历到，
+// 这种情况请先列举再调用 BatchAll。列举失败或者 ctx 取消时返回 err 以及已经完成的结果
+func (m *BucketManager) BatchWhere(ctx context.Context, bucket, prefix string, predicate func(ListItem) (op string, keep bool), concurrency int) (rets []BatchOpRet, err error) {
+	if predicate == nil {
+		return nil, errors.New("predicate is nil")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	opCh := make(chan string)
+	walkErr := make(chan error, 1)
+	go func() {
+		defer close(opCh)
+		walkErr <- m.WalkPrefix(ctx, bucket, prefix, nil, func(item ListItem) error {
+			op, keep := predicate(item)
+			if !keep {
+				return nil
+			}
+			select {
+			case opCh <- op:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	results, err := m.BatchFromChan(ctx, opCh, concurrency)
+	if err != nil {
+		return
+	}
+	for ret := range results {
+		rets = append(rets, ret)
+	}
+	err = <-walkErr
+	return
+}
+
+// batchChunk 发送一批操作，请求失败或者结果数量与操作数量不一致时为每个操作生成包含错误信息的结果，
+// 保证返回的结果与 ops 一一对应，err 为请求失败的错误
+func (m *BucketManager) batchChunk(ctx context.Context, ops []string) (rets []BatchOpRet, err error) {
+	err = m.batch(ctx, ops, &rets)
+	if err == nil && len(rets) != len(ops) {
+		err = fmt.Errorf("batch returned %d results for %d operations", len(rets), len(ops))
+	}
+	if err == nil {
+		return
+	}
+	code := 0
+	if errInfo, ok := err.(*ErrorInfo); ok {
+		code = errInfo.Code
+	}
+	rets = make([]BatchOpRet, len(ops))
+	for i := range rets {
+		rets[i].Code = code
+		rets[i].Data.Error = err.Error()
+	}
+	return
+}
+
+// batchOpError 将批量操作中单个操作的错误码转换为对应的错误，612 为 ErrNoSuchEntry，其他为 *ErrorInfo（631 可以使用 IsNoSuchBucket 判断）
+func batchOpError(code int, message string) error {
+	if code == ErrorCodeNoSuchEntry {
+		return ErrNoSuchEntry
+	}
+	return &ErrorInfo{Code: code, Err: message}
+}
+
+// DeleteMany 批量删除空间中的文件，返回每个文件对应的结果，删除成功为 nil，文件不存在为 ErrNoSuchEntry，其他错误参见 batchOpError。
+// 重复的文件只会删除一次，超过 1000 个文件时会分多次请求，请求失败时返回 err 以及已经完成的结果
+func (m *BucketManager) DeleteMany(ctx context.Context, bucket string, keys []string) (results map[string]error, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	unique := make([]string, 0, len(keys))
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, key)
+		}
+	}
+
+	results = make(map[string]error, len(unique))
+	for start := 0; start < len(unique); start += batchLimit {
+		end := start + batchLimit
+		if end > len(unique) {
+			end = len(unique)
+		}
+		chunk := unique[start:end]
+		ops := make([]string, len(chunk))
+		for i, key := range chunk {
+			ops[i] = URIDelete(bucket, key)
+		}
+
+		var rets []BatchOpRet
+		if err = m.batch(ctx, ops, &rets); err != nil {
+			return
+		}
+		if len(rets) != len(chunk) {
+			err = fmt.Errorf("batch delete returned %d results for %d operations", len(rets), len(chunk))
+			return
+		}
+		for i, ret := range rets {
+			if ret.Code == 200 {
+				results[chunk[i]] = nil
+			} else {
+				results[chunk[i]] = batchOpError(ret.Code, ret.Data.Error)
+			}
+		}
+	}
+	return
+}
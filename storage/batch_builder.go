@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+// BatchOperations 批量操作的构造器，操作按照添加的顺序保存，Build 返回的操作以及 DoBatch 返回的结果都与添加的顺序一致，
+// 因此第 i 个添加的操作的结果总是 DoBatch 返回的第 i 个结果。不能被多个 goroutine 同时修改
+type BatchOperations struct {
+	ops []string
+}
+
+// NewBatchOperations 创建一个空的批量操作构造器
+func NewBatchOperations() *BatchOperations {
+	return &BatchOperations{}
+}
+
+// Add 添加一个已经生成的操作，如 URIStat 等方法的返回值
+func (b *BatchOperations) Add(op string) *BatchOperations {
+	b.ops = append(b.ops, op)
+	return b
+}
+
+// Stat 添加查询文件信息的操作，参见 URIStat
+func (b *BatchOperations) Stat(bucket, key string) *BatchOperations {
+	return b.Add(URIStat(bucket, key))
+}
+
+// Delete 添加删除文件的操作，参见 URIDelete
+func (b *BatchOperations) Delete(bucket, key string) *BatchOperations {
+	return b.Add(URIDelete(bucket, key))
+}
+
+// Copy 添加复制文件的操作，参见 URICopy
+func (b *BatchOperations) Copy(srcBucket, srcKey, destBucket, destKey string, force bool) *BatchOperations {
+	return b.Add(URICopy(srcBucket, srcKey, destBucket, destKey, force))
+}
+
+// Move 添加移动文件的操作，参见 URIMove
+func (b *BatchOperations) Move(srcBucket, srcKey, destBucket, destKey string, force bool) *BatchOperations {
+	return b.Add(URIMove(srcBucket, srcKey, destBucket, destKey, force))
+}
+
+// ChangeMime 添加修改文件 MimeType 的操作，参见 URIChangeMime
+func (b *BatchOperations) ChangeMime(bucket, key, newMime string) *BatchOperations {
+	return b.Add(URIChangeMime(bucket, key, newMime))
+}
+
+// ChangeType 添加修改文件存储类型的操作，参见 URIChangeType
+func (b *BatchOperations) ChangeType(bucket, key string, fileType int) *BatchOperations {
+	return b.Add(URIChangeType(bucket, key, fileType))
+}
+
+// DeleteAfterDays 添加设置文件生命周期的操作，参见 URIDeleteAfterDays
+func (b *BatchOperations) DeleteAfterDays(bucket, key string, days int) *BatchOperations {
+	return b.Add(URIDeleteAfterDays(bucket, key, days))
+}
+
+// RestoreAr 添加解冻归档文件的操作，参见 URIRestoreAr
+func (b *BatchOperations) RestoreAr(bucket, key string, afterDay int) *BatchOperations {
+	return b.Add(URIRestoreAr(bucket, key, afterDay))
+}
+
+// Len 返回已经添加的操作数量
+func (b *BatchOperations) Len() int {
+	return len(b.ops)
+}
+
+// Build 按照添加的顺序返回所有操作，可以直接传给 Batch、BatchAll 等方法，修改返回的切片不会影响构造器
+func (b *BatchOperations) Build() []string {
+	ops := make([]string, len(b.ops))
+	copy(ops, b.ops)
+	return ops
+}
+
+// doBatchConcurrency DoBatch 最多同时发送的批次数量
+const doBatchConcurrency = 4
+
+// DoBatch 发送 ops 中的所有操作，返回的 rets 与添加操作的顺序一一对应。超过 1000 个操作时会分多次请求，
+// 最多同时发送 4 批，各批次完成的顺序不影响结果的顺序，参见 BatchAll
+func (m *BucketManager) DoBatch(ctx context.Context, ops *BatchOperations) (rets []BatchOpRet, err error) {
+	if ops == nil {
+		return nil, errors.New("batch operations is nil")
+	}
+	return m.BatchAll(ctx, ops.Build(), doBatchConcurrency)
+}
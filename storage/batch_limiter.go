@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// batchLimiter 按照 AIMD（加性增、乘性减）的方式调整同时发送的批数：
+// 某一批遇到限流（429 或 573）时减半，之后每连续成功 limit 批增加 1，最大为 max，最小为 1
+type batchLimiter struct {
+	mu       sync.Mutex
+	limit    int
+	max      int
+	inFlight int
+	success  int
+	wake     chan struct{}
+	onChange func(concurrency int)
+}
+
+func newBatchLimiter(max int, onChange func(concurrency int)) *batchLimiter {
+	return &batchLimiter{limit: max, max: max, wake: make(chan struct{}), onChange: onChange}
+}
+
+// acquire 等待直到正在发送的批数小于当前的并发数，ctx 取消时返回 false
+func (l *batchLimiter) acquire(ctx context.Context) bool {
+	for {
+		l.mu.Lock()
+		if l.inFlight < l.limit {
+			l.inFlight++
+			l.mu.Unlock()
+			return true
+		}
+		wake := l.wake
+		l.mu.Unlock()
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// release 在一批完成后调用，throttled 表示该批是否遇到限流
+func (l *batchLimiter) release(throttled bool) {
+	l.mu.Lock()
+	l.inFlight--
+	old := l.limit
+	if throttled {
+		l.success = 0
+		if l.limit = l.limit / 2; l.limit < 1 {
+			l.limit = 1
+		}
+	} else if l.limit < l.max {
+		if l.success++; l.success >= l.limit {
+			l.success = 0
+			l.limit++
+		}
+	}
+	current := l.limit
+	close(l.wake)
+	l.wake = make(chan struct{})
+	l.mu.Unlock()
+
+	if current != old && l.onChange != nil {
+		l.onChange(current)
+	}
+}
+
+// current 返回当前的并发数
+func (l *batchLimiter) current() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// isThrottled 判断批量请求的错误是否为服务端限流（429 或者七牛的 573）
+func isThrottled(err error) bool {
+	errInfo, ok := err.(*ErrorInfo)
+	return ok && (errInfo.Code == http.StatusTooManyRequests || errInfo.Code == ErrorCodeTooManyRequests)
+}
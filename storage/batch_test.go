@@ -0,0 +1,627 @@
+// +build unit
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatchStatMulti(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		rets := make([]string, 0, len(r.PostForm["op"]))
+		for _, op := range r.PostForm["op"] {
+			switch op {
+			case URIStat("b1", "a"):
+				rets = append(rets, `{"code":200,"data":{"hash":"h1","fsize":1,"endUser":"u1"}}`)
+			case URIStat("b2", "a"):
+				rets = append(rets, `{"code":200,"data":{"hash":"h2","fsize":2,"type":1}}`)
+			default:
+				rets = append(rets, `{"code":612,"data":{"error":"no such file or directory"}}`)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("[" + strings.Join(rets, ",") + "]"))
+	})
+	defer srv.Close()
+
+	b1a, b2a, missing := BucketKey{"b1", "a"}, BucketKey{"b2", "a"}, BucketKey{"b2", "missing"}
+	infos, errs, err := m.BatchStatMulti([]BucketKey{b1a, b2a, missing, b1a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 || infos[b1a].Hash != "h1" || infos[b1a].EndUser != "u1" || infos[b2a].Type != 1 {
+		t.Fatalf("unexpected infos: %+v", infos)
+	}
+	entryErr, ok := errs[missing].(*EntryError)
+	if len(errs) != 1 || !ok || entryErr.Bucket != "b2" || entryErr.Key != "missing" || entryErr.Unwrap() != ErrNoSuchEntry {
+		t.Fatalf("unexpected errs: %+v", errs)
+	}
+}
+
+func TestBatchFromChan(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests int
+	)
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		ops := r.PostForm["op"]
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		if len(ops) > 1000 {
+			t.Errorf("too many ops in one batch: %d", len(ops))
+		}
+		// 第二批请求失败
+		if ops[0] == "1000" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(599)
+			w.Write([]byte(`{"error":"server error"}`))
+			return
+		}
+		rets := make([]string, 0, len(ops))
+		for _, op := range ops {
+			rets = append(rets, `{"code":200,"data":{"hash":"`+op+`"}}`)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("[" + strings.Join(rets, ",") + "]"))
+	})
+	defer srv.Close()
+
+	const count = 2500
+	ops := make(chan string)
+	go func() {
+		defer close(ops)
+		for i := 0; i < count; i++ {
+			ops <- strconv.Itoa(i)
+		}
+	}()
+	results, err := m.BatchFromChan(context.Background(), ops, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i, failed := 0, 0
+	for ret := range results {
+		if ret.Code == 200 && ret.Data.Hash != strconv.Itoa(i) {
+			t.Fatalf("result %d out of order: %s", i, ret.Data.Hash)
+		} else if ret.Code == 599 {
+			failed++
+		}
+		i++
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if i != count || failed != 1000 || requests != 3 {
+		t.Fatalf("results = %d, failed = %d, requests = %d", i, failed, requests)
+	}
+
+	if _, err = m.BatchFromChan(context.Background(), nil, 1); err == nil {
+		t.Fatal("expect error for nil channel")
+	}
+}
+
+func TestBatchStatGroupByStorageType(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		rets := make([]string, 0, len(r.PostForm["op"]))
+		for i := range r.PostForm["op"] {
+			if i == 1 {
+				rets = append(rets, `{"code":612,"data":{"error":"no such file or directory"}}`)
+			} else {
+				rets = append(rets, fmt.Sprintf(`{"code":200,"data":{"hash":"h%d","type":%d}}`, i, i%3))
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("[" + strings.Join(rets, ",") + "]"))
+	})
+	defer srv.Close()
+
+	infos, errs, err := m.BatchStat(context.Background(), "bucket", []string{"a", "b", "c", "d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 4 || len(errs) != 4 || errs[0] != nil || errs[1] == nil {
+		t.Fatalf("infos = %+v, errs = %v", infos, errs)
+	}
+	if entryErr, ok := errs[1].(*EntryError); !ok || entryErr.Key != "b" || entryErr.Unwrap() != ErrNoSuchEntry {
+		t.Fatalf("unexpected error: %v", errs[1])
+	}
+	if infos[2].Type != int(StorageTypeArchive) || infos[3].Type != int(StorageTypeStandard) {
+		t.Fatalf("storage type should be populated: %+v", infos)
+	}
+
+	groups := GroupByStorageType([]FileInfo{infos[0], infos[2], infos[3]})
+	if len(groups[StorageTypeStandard]) != 2 || groups[StorageTypeStandard][1].Hash != "h3" || len(groups[StorageTypeArchive]) != 1 {
+		t.Fatalf("unexpected groups: %+v", groups)
+	}
+}
+
+func TestBatchHostError(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {})
+	srv.Close()
+
+	_, err := m.Batch([]string{URIStat("bucket", "key")})
+	hostErr, ok := err.(*BatchHostError)
+	if !ok {
+		t.Fatalf("want *BatchHostError, got %v", err)
+	}
+	if hostErr.Host != m.Cfg.CentralRsHost || hostErr.Default || hostErr.Unwrap() == nil {
+		t.Fatalf("unexpected error: %+v", hostErr)
+	}
+	if !strings.Contains(err.Error(), "configured CentralRsHost "+m.Cfg.CentralRsHost) {
+		t.Fatalf("unexpected message: %s", err)
+	}
+}
+
+func TestBatchHost(t *testing.T) {
+	var paths []string
+	batchSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, "batch:"+r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"code":200,"data":{"hash":"h"}}]`))
+	}))
+	defer batchSrv.Close()
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, "rs:"+r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"hash":"h"}`))
+	})
+	defer srv.Close()
+	m.Cfg.BatchHost = strings.TrimPrefix(batchSrv.URL, "http://")
+
+	if _, err := m.Batch([]string{URIStat("bucket", "key")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Stat("bucket", "key"); err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 || paths[0] != "batch:/batch" || !strings.HasPrefix(paths[1], "rs:/stat/") {
+		t.Fatalf("unexpected requests: %v", paths)
+	}
+
+	batchSrv.Close()
+	_, err := m.Batch([]string{URIStat("bucket", "key")})
+	if hostErr, ok := err.(*BatchHostError); !ok || hostErr.Field != "BatchHost" || !strings.Contains(err.Error(), "configured BatchHost "+m.Cfg.BatchHost) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestDeleteMany(t *testing.T) {
+	var ops []string
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		ops = append(ops, r.PostForm["op"]...)
+		rets := make([]string, 0, len(r.PostForm["op"]))
+		for _, op := range r.PostForm["op"] {
+			switch op {
+			case URIDelete("bucket", "missing"):
+				rets = append(rets, `{"code":612,"data":{"error":"no such file or directory"}}`)
+			case URIDelete("bucket", "locked"):
+				rets = append(rets, `{"code":403,"data":{"error":"forbidden"}}`)
+			default:
+				rets = append(rets, `{"code":200}`)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("[" + strings.Join(rets, ",") + "]"))
+	})
+	defer srv.Close()
+
+	results, err := m.DeleteMany(context.Background(), "bucket", []string{"a", "missing", "a", "locked"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 3 || len(results) != 3 {
+		t.Fatalf("ops = %v, results = %v", ops, results)
+	}
+	if results["a"] != nil || results["missing"] != ErrNoSuchEntry {
+		t.Fatalf("unexpected results: %v", results)
+	}
+	if errInfo, ok := results["locked"].(*ErrorInfo); !ok || errInfo.Code != 403 {
+		t.Fatalf("unexpected error for locked: %v", results["locked"])
+	}
+}
+
+func TestBatchAllOrder(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		ops := r.PostForm["op"]
+		// 越靠前的批次完成得越晚，使各批次乱序完成
+		first, _ := strconv.Atoi(ops[0])
+		time.Sleep(time.Duration(3000-first) * time.Millisecond / 100)
+		rets := make([]string, 0, len(ops))
+		for _, op := range ops {
+			rets = append(rets, `{"code":200,"data":{"hash":"`+op+`"}}`)
+		}
+		if first == 2000 {
+			// 结果数量与操作数量不一致的批次整体视为失败
+			rets = rets[1:]
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("[" + strings.Join(rets, ",") + "]"))
+	})
+	defer srv.Close()
+
+	const count = 2500
+	ops := make([]string, count)
+	for i := range ops {
+		ops[i] = strconv.Itoa(i)
+	}
+	rets, err := m.BatchAll(context.Background(), ops, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rets) != count {
+		t.Fatalf("got %d results", len(rets))
+	}
+	for i, ret := range rets {
+		if i < 2000 && (ret.Code != 200 || ret.Data.Hash != ops[i]) {
+			t.Fatalf("result %d does not match op: %+v", i, ret)
+		}
+		if i >= 2000 && (ret.Code == 200 || ret.Data.Error == "") {
+			t.Fatalf("result %d should be an error: %+v", i, ret)
+		}
+	}
+}
+
+func TestBatchLimiter(t *testing.T) {
+	var changes []int
+	l := newBatchLimiter(8, func(concurrency int) { changes = append(changes, concurrency) })
+	ctx := context.Background()
+	for i := 0; i < 8; i++ {
+		if !l.acquire(ctx) {
+			t.Fatal("acquire failed")
+		}
+	}
+	l.release(true)
+	l.release(true)
+	if l.current() != 2 {
+		t.Fatalf("current = %d, want 2", l.current())
+	}
+	// 仍有 6 批正在发送，超过当前的并发数时需要等待
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	if l.acquire(cancelCtx) {
+		t.Fatal("acquire should wait when in-flight exceeds the limit")
+	}
+	for i := 0; i < 6; i++ {
+		l.release(false)
+	}
+	if l.current() != 4 || strings.Trim(fmt.Sprint(changes), "[]") != "4 2 3 4" {
+		t.Fatalf("current = %d, changes = %v", l.current(), changes)
+	}
+	l.release(true)
+	if l.current() != 2 {
+		t.Fatalf("current = %d, want 2", l.current())
+	}
+}
+
+func TestIsThrottled(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		want bool
+	}{
+		{&ErrorInfo{Code: http.StatusTooManyRequests}, true},
+		{&ErrorInfo{Code: ErrorCodeTooManyRequests}, true},
+		{&ErrorInfo{Code: http.StatusServiceUnavailable}, false},
+		{errors.New("too many requests"), false},
+		{nil, false},
+	} {
+		if got := isThrottled(c.err); got != c.want {
+			t.Errorf("isThrottled(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestBatchAllThrottled(t *testing.T) {
+	var mu sync.Mutex
+	requests, throttleCode := 0, http.StatusTooManyRequests
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		n, code := requests, throttleCode
+		mu.Unlock()
+		r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		if n == 1 {
+			w.WriteHeader(code)
+			w.Write([]byte(`{"error":"too many requests"}`))
+			return
+		}
+		rets := make([]string, len(r.PostForm["op"]))
+		for i := range rets {
+			rets[i] = `{"code":200}`
+		}
+		w.Write([]byte("[" + strings.Join(rets, ",") + "]"))
+	})
+	defer srv.Close()
+
+	var changes []int
+	m.Cfg.OnBatchConcurrency = func(concurrency int) {
+		mu.Lock()
+		changes = append(changes, concurrency)
+		mu.Unlock()
+	}
+	ops := make([]string, 3*batchLimit)
+	for i := range ops {
+		ops[i] = URIStat("bucket", strconv.Itoa(i))
+	}
+	rets, err := m.BatchAll(context.Background(), ops, 1)
+	if err != nil || len(rets) != len(ops) {
+		t.Fatalf("len(rets) = %d, err = %v", len(rets), err)
+	}
+	if rets[0].Code != http.StatusTooManyRequests || rets[batchLimit].Code != 200 {
+		t.Fatalf("unexpected rets: %+v %+v", rets[0], rets[batchLimit])
+	}
+	if len(changes) != 0 {
+		t.Fatalf("concurrency 1 should not change: %v", changes)
+	}
+
+	for _, code := range []int{http.StatusTooManyRequests, ErrorCodeTooManyRequests} {
+		mu.Lock()
+		requests, throttleCode, changes = 0, code, nil
+		mu.Unlock()
+		if _, err = m.BatchAll(context.Background(), ops, 4); err != nil {
+			t.Fatal(err)
+		}
+		mu.Lock()
+		if len(changes) == 0 || changes[0] != 2 {
+			t.Fatalf("code %d: unexpected changes: %v", code, changes)
+		}
+		mu.Unlock()
+	}
+}
+
+func TestBatchStatSmart(t *testing.T) {
+	var stats []string
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/batch" {
+			w.Write([]byte(`[{"code":200,"data":{"hash":"small","fsize":10}},{"code":200,"data":{"hash":"big","fsize":100}},` +
+				`{"code":612,"data":{"error":"no such file or directory"}},{"code":200,"data":{"hash":"gone","fsize":200}}]`))
+			return
+		}
+		stats = append(stats, r.URL.Path)
+		if r.URL.Query().Get("needparts") != "true" {
+			t.Errorf("needparts not set: %s", r.URL.RawQuery)
+		}
+		if r.URL.Path == URIStat("bucket", "gone") {
+			w.WriteHeader(612)
+			w.Write([]byte(`{"error":"no such file or directory"}`))
+			return
+		}
+		w.Write([]byte(`{"hash":"big","fsize":100,"parts":[60,40]}`))
+	})
+	defer srv.Close()
+
+	infos, errs, err := m.BatchStatSmart(context.Background(), "bucket", []string{"small", "big", "missing", "gone"}, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 || stats[0] != URIStat("bucket", "big") || stats[1] != URIStat("bucket", "gone") {
+		t.Fatalf("unexpected stats: %v", stats)
+	}
+	if infos[0].Parts != nil || len(infos[1].Parts) != 2 || errs[0] != nil || errs[1] != nil {
+		t.Fatalf("infos = %+v, errs = %v", infos, errs)
+	}
+	if e, ok := errs[2].(*EntryError); !ok || e.Err != ErrNoSuchEntry {
+		t.Fatalf("errs[2] = %v", errs[2])
+	}
+	if e, ok := errs[3].(*EntryError); !ok || e.Err != ErrNoSuchEntry || infos[3].Hash != "gone" {
+		t.Fatalf("errs[3] = %v, infos[3] = %+v", errs[3], infos[3])
+	}
+}
+
+func TestBatchBodyLimit(t *testing.T) {
+	ops := []string{URIStat("bucket", "a"), URIStat("bucket", "b/c"), URIStat("bucket", strings.Repeat("k", 50))}
+	want := 0
+	for i, op := range ops {
+		want += len("op=" + url.QueryEscape(op))
+		if i > 0 {
+			want++
+		}
+	}
+	if size := EstimateBatchSize(ops); size != want || size != len(url.Values{"op": ops}.Encode()) {
+		t.Fatalf("size = %d, want %d", size, want)
+	}
+
+	var requests [][]string
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		requests = append(requests, r.PostForm["op"])
+		rets := make([]string, len(r.PostForm["op"]))
+		for i, op := range r.PostForm["op"] {
+			rets[i] = fmt.Sprintf(`{"code":200,"data":{"hash":%q}}`, op)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("[" + strings.Join(rets, ",") + "]"))
+	})
+	defer srv.Close()
+
+	m.Cfg.BatchBodyLimit = EstimateBatchSize(ops[2:])
+	_, err := m.Batch(ops)
+	if e, ok := err.(*BatchBodyTooLargeError); !ok || e.Size != want || e.Unwrap() != ErrBatchBodyTooLarge || len(requests) != 0 {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	m.Cfg.BatchAutoSplit = true
+	rets, err := m.Batch(ops)
+	if err != nil || len(rets) != 3 || len(requests) != 3 {
+		t.Fatalf("rets = %+v, requests = %v, err = %v", rets, requests, err)
+	}
+	for i, ret := range rets {
+		if ret.Data.Hash != ops[i] {
+			t.Fatalf("rets[%d] = %+v, want result of %s", i, ret, ops[i])
+		}
+	}
+
+	m.Cfg.BatchBodyLimit = 10
+	if _, err = m.Batch(ops); err == nil {
+		t.Fatal("expect error when a single operation exceeds the limit")
+	}
+}
+
+func TestSupportedBatchVerbs(t *testing.T) {
+	defer func(verbs []string) { batchVerbs = verbs }(SupportedBatchVerbs())
+
+	if err := RegisterBatchVerb("a/b"); err == nil {
+		t.Fatal("expect error for invalid verb")
+	}
+	for _, verb := range []string{"tagging", "stat", "tagging"} {
+		if err := RegisterBatchVerb(verb); err != nil {
+			t.Fatal(err)
+		}
+	}
+	verbs := SupportedBatchVerbs()
+	if want := "stat,delete,copy,move,chgm,chtype,deleteAfterDays,restoreAr,tagging"; strings.Join(verbs, ",") != want {
+		t.Fatalf("want = %s, got = %v", want, verbs)
+	}
+	verbs[0] = "changed"
+	if SupportedBatchVerbs()[0] != "stat" {
+		t.Fatal("returned verbs should be a copy")
+	}
+}
+
+func TestBatchWhere(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/list":
+			w.Write([]byte(`{"items":[{"key":"img/a.jpg"},{"key":"img/b.txt"},{"key":"img/c.jpg"}]}`))
+		case "/batch":
+			r.ParseForm()
+			rets := make([]string, 0, len(r.PostForm["op"]))
+			for _, op := range r.PostForm["op"] {
+				rets = append(rets, `{"code":200,"data":{"hash":"`+op+`"}}`)
+			}
+			w.Write([]byte("[" + strings.Join(rets, ",") + "]"))
+		default:
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+	})
+	defer srv.Close()
+
+	rets, err := m.BatchWhere(context.Background(), "bucket", "img/", func(item ListItem) (string, bool) {
+		return URIDelete("bucket", item.Key), strings.HasSuffix(item.Key, ".jpg")
+	}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rets) != 2 || rets[0].Data.Hash != URIDelete("bucket", "img/a.jpg") || rets[1].Data.Hash != URIDelete("bucket", "img/c.jpg") {
+		t.Fatalf("unexpected rets: %+v", rets)
+	}
+
+	if _, err = m.BatchWhere(context.Background(), "bucket", "", nil, 1); err == nil {
+		t.Fatal("expect error for nil predicate")
+	}
+}
+
+func TestBatchOperationsOrder(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		rets := make([]string, 0, len(r.PostForm["op"]))
+		for _, op := range r.PostForm["op"] {
+			rets = append(rets, `{"code":200,"data":{"hash":"`+op+`"}}`)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("[" + strings.Join(rets, ",") + "]"))
+	})
+	defer srv.Close()
+
+	ops := NewBatchOperations().
+		Move("bucket", "z", "bucket", "y", false).
+		Stat("bucket", "a").
+		ChangeType("bucket", "m", 1).
+		Delete("bucket", "b").
+		Copy("bucket", "c", "bucket", "d", true).
+		ChangeMime("bucket", "e", "text/plain").
+		DeleteAfterDays("bucket", "f", 7).
+		RestoreAr("bucket", "g", 1).
+		Add(URIStat("bucket", "0"))
+	want := []string{
+		URIMove("bucket", "z", "bucket", "y", false),
+		URIStat("bucket", "a"),
+		URIChangeType("bucket", "m", 1),
+		URIDelete("bucket", "b"),
+		URICopy("bucket", "c", "bucket", "d", true),
+		URIChangeMime("bucket", "e", "text/plain"),
+		URIDeleteAfterDays("bucket", "f", 7),
+		URIRestoreAr("bucket", "g", 1),
+		URIStat("bucket", "0"),
+	}
+	built := ops.Build()
+	if ops.Len() != len(want) || strings.Join(built, ",") != strings.Join(want, ",") {
+		t.Fatalf("want = %v, got = %v", want, built)
+	}
+	built[0] = "changed"
+	if ops.Build()[0] != want[0] {
+		t.Fatal("Build should return a copy")
+	}
+
+	rets, err := m.DoBatch(context.Background(), ops)
+	if err != nil || len(rets) != len(want) {
+		t.Fatalf("rets = %+v, err = %v", rets, err)
+	}
+	for i, ret := range rets {
+		if ret.Data.Hash != want[i] {
+			t.Fatalf("result %d does not match op %s: %+v", i, want[i], ret)
+		}
+	}
+}
+
+func TestDoBatchChunksOutOfOrder(t *testing.T) {
+	ops := NewBatchOperations()
+	for i := 0; i < 2500; i++ {
+		key := fmt.Sprintf("%04d", i)
+		switch i % 4 {
+		case 0:
+			ops.Stat("bucket", key)
+		case 1:
+			ops.Delete("bucket", key)
+		case 2:
+			ops.Copy("bucket", key, "bucket", key+".bak", true)
+		default:
+			ops.ChangeType("bucket", key, 1)
+		}
+	}
+	want := ops.Build()
+	index := make(map[string]int, len(want))
+	for i, op := range want {
+		index[op] = i
+	}
+
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		chunk := r.PostForm["op"]
+		// 越靠前的批次完成得越晚，后面的批次先返回结果
+		time.Sleep(time.Duration(3-index[chunk[0]]/1000) * 50 * time.Millisecond)
+		rets := make([]string, 0, len(chunk))
+		for _, op := range chunk {
+			rets = append(rets, `{"code":200,"data":{"hash":"`+op+`"}}`)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("[" + strings.Join(rets, ",") + "]"))
+	})
+	defer srv.Close()
+
+	rets, err := m.DoBatch(context.Background(), ops)
+	if err != nil || len(rets) != len(want) {
+		t.Fatalf("got %d results, err = %v", len(rets), err)
+	}
+	for i, ret := range rets {
+		if ret.Code != 200 || ret.Data.Hash != want[i] {
+			t.Fatalf("result %d does not match op %s: %+v", i, want[i], ret)
+		}
+	}
+}
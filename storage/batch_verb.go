@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+var (
+	batchVerbsMu sync.RWMutex
+
+	// batchVerbs 批量操作支持的指令，即操作 URI 的第一段，如 /stat/<EncodedEntry> 中的 stat
+	batchVerbs = []string{"stat", "delete", "copy", "move", "chgm", "chtype", "deleteAfterDays", "restoreAr"}
+)
+
+// RegisterBatchVerb 注册 SDK 没有内置的批量操作指令，用于服务端新增了指令而 SDK 还没有提供对应的 URI 方法的情况，
+// 注册后可以通过 SupportedBatchVerbs 获取。指令不能为空或者包含 /，重复注册同一个指令不会报错
+func RegisterBatchVerb(verb string) (err error) {
+	if verb == "" || strings.Contains(verb, "/") {
+		return errors.New("invalid batch verb: " + verb)
+	}
+	batchVerbsMu.Lock()
+	defer batchVerbsMu.Unlock()
+	for _, v := range batchVerbs {
+		if v == verb {
+			return
+		}
+	}
+	batchVerbs = append(batchVerbs, verb)
+	return
+}
+
+// SupportedBatchVerbs 返回批量操作支持的所有指令，包括内置的指令以及通过 RegisterBatchVerb 注册的指令，
+// 按照注册的顺序返回，修改返回的切片不会影响注册的指令
+func SupportedBatchVerbs() (verbs []string) {
+	batchVerbsMu.RLock()
+	defer batchVerbsMu.RUnlock()
+	verbs = make([]string, len(batchVerbs))
+	copy(verbs, batchVerbs)
+	return
+}
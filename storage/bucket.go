@@ -6,17 +6,22 @@ package storage
 // 重复的逻辑
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
-	"os"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/qiniu/go-sdk/v7/auth"
 	"github.com/qiniu/go-sdk/v7/client"
@@ -133,12 +138,36 @@ func (f *FileInfo) String() string {
 	return str
 }
 
+// IsFrozen 判断文件是否为冻结状态，即归档/深度归档文件既没有解冻也没有在解冻中，此时文件不能直接下载。
+// 冻结的归档文件不返回 restoreStatus 字段，RestoreStatus 为 0 时即表示冻结
+func (f *FileInfo) IsFrozen() bool {
+	return f.isArchived() && f.RestoreStatus != restoreStatusRestoring && f.RestoreStatus != restoreStatusRestored
+}
+
+// IsRestoring 判断归档/深度归档文件是否正在解冻中
+func (f *FileInfo) IsRestoring() bool {
+	return f.isArchived() && f.RestoreStatus == restoreStatusRestoring
+}
+
+// IsRestored 判断归档/深度归档文件是否已经解冻完成，解冻完成的文件可以直接下载
+func (f *FileInfo) IsRestored() bool {
+	return f.isArchived() && f.RestoreStatus == restoreStatusRestored
+}
+
+func (f *FileInfo) isArchived() bool {
+	t := StorageType(f.Type)
+	return t == StorageTypeArchive || t == StorageTypeDeepArchive
+}
+
 // FetchRet 资源抓取的返回值
 type FetchRet struct {
 	Hash     string `json:"hash"`
 	Fsize    int64  `json:"fsize"`
 	MimeType string `json:"mimeType"`
 	Key      string `json:"key"`
+
+	// 文件的 md5 值，仅在服务端返回时有值，为空时可以通过 Stat 获取
+	Md5 string `json:"md5,omitempty"`
 }
 
 func (r *FetchRet) String() string {
@@ -147,9 +176,24 @@ func (r *FetchRet) String() string {
 	str += fmt.Sprintf("Hash:     %s\n", r.Hash)
 	str += fmt.Sprintf("Fsize:    %d\n", r.Fsize)
 	str += fmt.Sprintf("MimeType: %s\n", r.MimeType)
+	if r.Md5 != "" {
+		str += fmt.Sprintf("Md5:      %s\n", r.Md5)
+	}
 	return str
 }
 
+// VerifyFetchResult 校验抓取到的文件的 md5 是否与 expectedMd5（十六进制字符串，不区分大小写）一致，
+// 不一致时返回 ErrMd5Mismatch，服务端没有返回 md5 时返回 ErrMd5Unavailable
+func VerifyFetchResult(expectedMd5 string, ret FetchRet) error {
+	if ret.Md5 == "" {
+		return ErrMd5Unavailable
+	}
+	if !strings.EqualFold(expectedMd5, ret.Md5) {
+		return ErrMd5Mismatch
+	}
+	return nil
+}
+
 // BatchOpRet 为批量执行操作的返回值
 // 批量操作支持 stat，copy，delete，move，chgm，chtype，deleteAfterDays几个操作
 // 其中 stat 为获取文件的基本信息，如果文件存在则返回基本信息，如果文件不存在返回 error 。
@@ -171,6 +215,12 @@ type BucketManager struct {
 	Client *client.Client
 	Mac    *auth.Credentials
 	Cfg    *Config
+
+	// entries 缓存 EncodedEntry 的结果，Config.EntryCacheSize 大于 0 时创建
+	entries *entryCache
+
+	// bucketInfos 缓存 GetBucketInfo 的结果，Config.BucketInfoCacheTTL 大于 0 时创建
+	bucketInfos *bucketInfoCache
 }
 
 // NewBucketManager 用来构建一个新的资源管理对象
@@ -182,11 +232,18 @@ func NewBucketManager(mac *auth.Credentials, cfg *Config) *BucketManager {
 		cfg.CentralRsHost = DefaultRsHost
 	}
 
-	return &BucketManager{
-		Client: &client.DefaultClient,
+	m := &BucketManager{
+		Client: newClient(cfg),
 		Mac:    mac,
 		Cfg:    cfg,
 	}
+	if cfg.EntryCacheSize > 0 {
+		m.entries = newEntryCache(cfg.EntryCacheSize)
+	}
+	if cfg.BucketInfoCacheTTL > 0 {
+		m.bucketInfos = newBucketInfoCache(cfg.BucketInfoCacheTTL)
+	}
+	return m
 }
 
 // NewBucketManagerEx 用来构建一个新的资源管理对象
@@ -196,17 +253,148 @@ func NewBucketManagerEx(mac *auth.Credentials, cfg *Config, clt *client.Client)
 	}
 
 	if clt == nil {
-		clt = &client.DefaultClient
+		clt = newClient(cfg)
 	}
 	if cfg.CentralRsHost == "" {
 		cfg.CentralRsHost = DefaultRsHost
 	}
 
-	return &BucketManager{
+	m := &BucketManager{
 		Client: clt,
 		Mac:    mac,
 		Cfg:    cfg,
 	}
+	if cfg.EntryCacheSize > 0 {
+		m.entries = newEntryCache(cfg.EntryCacheSize)
+	}
+	if cfg.BucketInfoCacheTTL > 0 {
+		m.bucketInfos = newBucketInfoCache(cfg.BucketInfoCacheTTL)
+	}
+	return m
+}
+
+// newClient 根据 Config 中的超时时间以及 DisableCompression 创建 Client，都没有设置时使用共享的 client.DefaultClient
+func newClient(cfg *Config) *client.Client {
+	if cfg.DialTimeout == 0 && cfg.TLSHandshakeTimeout == 0 && !cfg.DisableCompression {
+		return &client.DefaultClient
+	}
+	dialTimeout := cfg.DialTimeout
+	if dialTimeout == 0 {
+		dialTimeout = 30 * time.Second
+	}
+	tlsHandshakeTimeout := cfg.TLSHandshakeTimeout
+	if tlsHandshakeTimeout == 0 {
+		tlsHandshakeTimeout = 10 * time.Second
+	}
+	// 与 http.DefaultTransport 的配置保持一致
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   dialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   tlsHandshakeTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+		DisableCompression:    cfg.DisableCompression,
+	}
+	if cfg.DisableCompression {
+		return &client.Client{Client: &http.Client{Transport: identityEncodingTransport{transport}}}
+	}
+	return &client.Client{Client: &http.Client{Transport: transport}}
+}
+
+// identityEncodingTransport 在请求没有指定 Accept-Encoding 时设置为 identity，要求服务端和代理不要压缩响应
+type identityEncodingTransport struct {
+	*http.Transport
+}
+
+func (t identityEncodingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Accept-Encoding") == "" {
+		r := new(http.Request)
+		*r = *req
+		r.Header = make(http.Header, len(req.Header)+1)
+		for k, v := range req.Header {
+			r.Header[k] = v
+		}
+		r.Header.Set("Accept-Encoding", "identity")
+		req = r
+	}
+	return t.Transport.RoundTrip(req)
+}
+
+// Close 关闭 BucketManager 所使用的连接池中的空闲连接，可以在服务退出或者不再使用该对象时安全地调用，
+// 调用后 BucketManager 仍然可以继续使用。
+// 使用共享的 client.DefaultClient 或者 http.DefaultTransport 时不做任何操作，以免影响其他使用者，
+// 即使 client.DefaultClient 被替换为自定义的 Transport 也是如此；
+// 通过 NewBucketManagerEx 传入的 Client 视为由该 BucketManager 所有，多个 BucketManager 共享同一个 Client 时，
+// Close 会关闭所有使用者的空闲连接（正在使用的连接不受影响）
+func (m *BucketManager) Close() {
+	if m.Client == nil || m.Client == &client.DefaultClient || m.Client.Client == nil {
+		return
+	}
+	transport := m.Client.Client.Transport
+	if transport == nil || transport == http.DefaultTransport {
+		return
+	}
+	if t, ok := transport.(interface{ CloseIdleConnections() }); ok {
+		t.CloseIdleConnections()
+	}
+}
+
+// entryCall 向 hostCategory 对应的服务发送针对文件的请求，请求的域名由第一个文件的空间决定，
+// entries 用于在发送请求前校验 key，以及将服务端返回的错误转换为 EntryError
+func (m *BucketManager) entryCall(ctx context.Context, hostCategory HostCategory, ret interface{}, path string, entries ...BucketKey) (err error) {
+	reqURL, err := m.entryURL(hostCategory, path, entries...)
+	if err != nil {
+		return
+	}
+	err = m.call(ctx, operationName(path), entries[0].Bucket, entries[0].Key, func(ctx context.Context) error {
+		return m.Client.CredentialedCall(ctx, m.Mac, auth.TokenQiniu, ret, "POST", reqURL, nil)
+	})
+	return entryError(err, entries...)
+}
+
+// entryURL 校验 entries 中的文件名，并返回针对文件的请求的完整 URL，请求的域名由第一个文件的空间决定
+func (m *BucketManager) entryURL(hostCategory HostCategory, path string, entries ...BucketKey) (reqURL string, err error) {
+	if err = m.checkEntries(entries...); err != nil {
+		return
+	}
+	reqHost, err := m.ReqHostByCategory(hostCategory, entries[0].Bucket)
+	if err != nil {
+		return
+	}
+	return fmt.Sprintf("%s%s", reqHost, path), nil
+}
+
+// checkEntries 在 Config.AutoValidateKeys 为 true 时检查 key 是否为合法的 UTF-8 字符串
+func (m *BucketManager) checkEntries(entries ...BucketKey) error {
+	if !m.Cfg.AutoValidateKeys {
+		return nil
+	}
+	for _, e := range entries {
+		if !utf8.ValidString(e.Key) {
+			return &EntryError{Bucket: e.Bucket, Key: e.Key, Err: ErrKeyNotUTF8}
+		}
+	}
+	return nil
+}
+
+// entryError 将服务端返回的 key 编码错误转换为包含 ErrKeyNotUTF8 的 EntryError，其他错误原样返回
+func entryError(err error, entries ...BucketKey) error {
+	errInfo, ok := err.(*ErrorInfo)
+	if !ok || errInfo.Code != http.StatusBadRequest || !strings.Contains(errInfo.Err, "utf8") || len(entries) == 0 {
+		return err
+	}
+	e := entries[0]
+	for _, candidate := range entries {
+		if !utf8.ValidString(candidate.Key) {
+			e = candidate
+			break
+		}
+	}
+	return &EntryError{Bucket: e.Bucket, Key: e.Key, Err: ErrKeyNotUTF8}
 }
 
 // UpdateObjectStatus 用来修改文件状态, 禁用和启用文件的可访问性
@@ -231,33 +419,27 @@ func (m *BucketManager) UpdateObjectStatus(bucketName string, key string, enable
 		status = "1"
 	}
 	path := fmt.Sprintf("/chstatus/%s/status/%s", ee, status)
-
-	reqHost, reqErr := m.RsReqHost(bucketName)
-	if reqErr != nil {
-		return reqErr
-	}
-	reqURL := fmt.Sprintf("%s%s", reqHost, path)
-	return m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil)
+	return m.entryCall(context.Background(), HostCategoryRs, nil, path, BucketKey{bucketName, key})
 }
 
 // CreateBucket 创建一个七牛存储空间
 func (m *BucketManager) CreateBucket(bucketName string, regionID RegionID) error {
-	reqURL := fmt.Sprintf("%s/mkbucketv3/%s/region/%s", getUcHost(m.Cfg.UseHTTPS), bucketName, string(regionID))
+	reqURL := fmt.Sprintf("%s/mkbucketv3/%s/region/%s", m.Cfg.centralUcReqHost(), bucketName, string(regionID))
 	return m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil)
 }
 
 // Buckets 用来获取空间列表，如果指定了 shared 参数为 true，那么一同列表被授权访问的空间
 func (m *BucketManager) Buckets(shared bool) (buckets []string, err error) {
-	reqURL := fmt.Sprintf("%s/buckets?shared=%v", getUcHost(m.Cfg.UseHTTPS), shared)
+	reqURL := fmt.Sprintf("%s/buckets?shared=%v", m.Cfg.centralUcReqHost(), shared)
 	err = m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, &buckets, "POST", reqURL, nil)
 	return
 }
 
 // DropBucket 删除七牛存储空间
 func (m *BucketManager) DropBucket(bucketName string) (err error) {
-	reqURL := fmt.Sprintf("%s/drop/%s", getUcHost(m.Cfg.UseHTTPS), bucketName)
+	reqURL := fmt.Sprintf("%s/drop/%s", m.Cfg.centralUcReqHost(), bucketName)
 	err = m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil)
-	return
+	return m.bucketInfoChanged(bucketName, err)
 }
 
 // Stat 用来获取一个文件的基本信息
@@ -265,142 +447,330 @@ func (m *BucketManager) Stat(bucket, key string) (FileInfo, error) {
 	return m.StatWithOpts(bucket, key, nil)
 }
 
+// StatOpts 查询文件信息的可选参数，归档/深度归档文件的解冻状态 RestoreStatus 总是会返回，不需要额外的参数
 type StatOpts struct {
 	NeedParts bool
 }
 
 // StatWithParts 用来获取一个文件的基本信息以及分片信息
 func (m *BucketManager) StatWithOpts(bucket, key string, opt *StatOpts) (info FileInfo, err error) {
-	reqHost, reqErr := m.RsReqHost(bucket)
-	if reqErr != nil {
-		err = reqErr
-		return
-	}
-
-	reqURL := fmt.Sprintf("%s%s", reqHost, URIStat(bucket, key))
+	path := "/stat/" + m.cachedEncodedEntry(bucket, key)
 	if opt != nil {
 		if opt.NeedParts {
-			reqURL += "?needparts=true"
+			path += "?needparts=true"
 		}
 	}
-	err = m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, &info, "POST", reqURL, nil)
+	err = m.entryCall(context.Background(), HostCategoryRs, &info, path, BucketKey{bucket, key})
 	return
 }
 
+// 归档/深度归档文件的解冻状态，参见 FileInfo.RestoreStatus
+const (
+	restoreStatusRestoring = 1
+	restoreStatusRestored  = 2
+)
+
+// ArchiveStatus 通过一次 stat 请求查询归档/深度归档文件的解冻状态以及分片信息，
+// restoring 表示正在解冻，restored 表示解冻完成，两者都为 false 时文件处于冻结状态或者不是归档文件
+func (m *BucketManager) ArchiveStatus(ctx context.Context, bucket, key string) (restoring, restored bool, parts []int64, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var info FileInfo
+	if err = m.entryCall(ctx, HostCategoryRs, &info, URIStat(bucket, key)+"?needparts=true", BucketKey{bucket, key}); err != nil {
+		return
+	}
+	return info.RestoreStatus == restoreStatusRestoring, info.RestoreStatus == restoreStatusRestored, info.Parts, nil
+}
+
+// Exists 用来判断空间中的文件是否存在
+func (m *BucketManager) Exists(bucket, key string) (exists bool, err error) {
+	_, err = m.Stat(bucket, key)
+	if err == nil {
+		return true, nil
+	}
+	if errInfo, ok := err.(*ErrorInfo); ok && errInfo.Code == ErrorCodeNoSuchEntry {
+		return false, nil
+	}
+	return false, err
+}
+
+// Matches 判断空间中的文件是否存在并且 hash 与 etag 一致，文件不存在时返回 ErrNoSuchEntry
+func (m *BucketManager) Matches(bucket, key, etag string) (matched bool, err error) {
+	info, err := m.Stat(bucket, key)
+	if err != nil {
+		return false, noSuchEntryError(err)
+	}
+	return info.Hash == etag, nil
+}
+
 // Delete 用来删除空间中的一个文件
 func (m *BucketManager) Delete(bucket, key string) (err error) {
-	reqHost, reqErr := m.RsReqHost(bucket)
-	if reqErr != nil {
-		err = reqErr
-		return
+	err = m.entryCall(context.Background(), HostCategoryRs, nil, "/delete/"+m.cachedEncodedEntry(bucket, key), BucketKey{bucket, key})
+	return
+}
+
+// DeleteIfHash 仅在文件的 hash 与 expectedHash 一致时删除空间中的文件，不一致时返回 ErrConditionNotMet
+// 比较由服务端在删除时原子地完成（delete 接口的 cond 参数），不存在先查询再删除之间文件被修改的问题
+func (m *BucketManager) DeleteIfHash(ctx context.Context, bucket, key, expectedHash string) (err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	err = m.entryCall(ctx, HostCategoryRs, nil, uriDeleteIfHash(bucket, key, expectedHash), BucketKey{bucket, key})
+	if errInfo, ok := err.(*ErrorInfo); ok && errInfo.Code == ErrorCodeConditionNotMet {
+		err = ErrConditionNotMet
 	}
-	reqURL := fmt.Sprintf("%s%s", reqHost, URIDelete(bucket, key))
-	err = m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil)
 	return
 }
 
-// Copy 用来创建已有空间中的文件的一个新的副本
+// Copy 用来创建已有空间中的文件的一个新的副本。
+// force 只影响目标文件：为 true 时覆盖已经存在的目标文件，为 false 时目标文件已存在会返回 614 错误；
+// 源文件不存在时无论 force 如何都会返回 612 错误。
+// force 为 true 时复制是幂等的，设置了 Config.RetryPolicy 时遇到 5xx 错误也会重试
 func (m *BucketManager) Copy(srcBucket, srcKey, destBucket, destKey string, force bool) (err error) {
-	reqHost, reqErr := m.RsReqHost(srcBucket)
-	if reqErr != nil {
-		err = reqErr
+	err = m.entryCall(overwriteContext(force), HostCategoryRs, nil, URICopy(srcBucket, srcKey, destBucket, destKey, force),
+		BucketKey{srcBucket, srcKey}, BucketKey{destBucket, destKey})
+	return
+}
+
+// CopyOpts 为复制文件时的可选参数
+type CopyOpts struct {
+	// 目标文件已经存在时是否强制覆盖
+	Force bool
+
+	// 目标文件的 endUser，为空时不设置，由服务端决定目标文件的 endUser
+	EndUser string
+
+	// 是否检测目标文件被覆盖，开启后会在复制前额外发送一次请求检查目标文件是否存在，
+	// 检测结果通过 CopyResult.Overwritten 返回
+	DetectOverwrite bool
+}
+
+// CopyResult 为 CopyWithOpts 的返回值
+type CopyResult struct {
+	// 目标文件在复制前已经存在并且被覆盖，仅在 CopyOpts.DetectOverwrite 为 true 时有效
+	Overwritten bool
+}
+
+// CopyWithOpts 用来创建已有空间中的文件的一个新的副本，可以通过 opts 指定目标文件的 endUser，以及检测目标文件是否被覆盖
+func (m *BucketManager) CopyWithOpts(srcBucket, srcKey, destBucket, destKey string, opts *CopyOpts) (result CopyResult, err error) {
+	entries := []BucketKey{{srcBucket, srcKey}, {destBucket, destKey}}
+	if err = m.checkEntries(entries...); err != nil {
 		return
 	}
 
-	reqURL := fmt.Sprintf("%s%s", reqHost, URICopy(srcBucket, srcKey, destBucket, destKey, force))
-	err = m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil)
+	destExists := false
+	if opts != nil && opts.DetectOverwrite && opts.Force {
+		if destExists, err = m.Exists(destBucket, destKey); err != nil {
+			return
+		}
+	}
+
+	err = m.entryCall(overwriteContext(opts != nil && opts.Force), HostCategoryRs, nil, URICopyWithOpts(srcBucket, srcKey, destBucket, destKey, opts), entries...)
+	if err == nil {
+		result.Overwritten = destExists
+	}
 	return
 }
 
-// Move 用来将空间中的一个文件移动到新的空间或者重命名
-func (m *BucketManager) Move(srcBucket, srcKey, destBucket, destKey string, force bool) (err error) {
-	reqHost, reqErr := m.RsReqHost(srcBucket)
-	if reqErr != nil {
-		err = reqErr
+// CopyAndSign 复制文件，并在复制成功后返回目标文件使用 mac 签名的私有下载链接，链接在 deadline（Unix 时间戳，单位：秒）后过期，
+// 链接的生成参见 MakePrivateURLv2。复制失败时返回错误，不会生成链接
+func (m *BucketManager) CopyAndSign(mac *auth.Credentials, srcBucket, srcKey, destBucket, destKey, domain string, force bool, deadline int64) (privateURL string, err error) {
+	if err = m.Copy(srcBucket, srcKey, destBucket, destKey, force); err != nil {
 		return
 	}
+	return MakePrivateURLv2(mac, domain, destKey, deadline), nil
+}
 
-	reqURL := fmt.Sprintf("%s%s", reqHost, URIMove(srcBucket, srcKey, destBucket, destKey, force))
-	err = m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil)
+// Move 用来将空间中的一个文件移动到新的空间或者重命名，force 的含义与 Copy 相同，只决定是否覆盖已经存在的目标文件。
+// 出错的请求可能实际上已经移动成功，此时重试会返回 612 错误，因此 Move 遇到 5xx 错误时不会重试
+func (m *BucketManager) Move(srcBucket, srcKey, destBucket, destKey string, force bool) (err error) {
+	err = m.entryCall(context.Background(), HostCategoryRs, nil, URIMove(srcBucket, srcKey, destBucket, destKey, force),
+		BucketKey{srcBucket, srcKey}, BucketKey{destBucket, destKey})
 	return
 }
 
+// MoveIfSourceExists 与 Move 相同，但会先检查源文件是否存在，不存在时直接返回 ErrNoSuchEntry，
+// 检查之后源文件被删除导致移动失败时同样返回 ErrNoSuchEntry。force 只决定是否覆盖已经存在的目标文件
+func (m *BucketManager) MoveIfSourceExists(ctx context.Context, srcBucket, srcKey, destBucket, destKey string, force bool) (err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	src := BucketKey{srcBucket, srcKey}
+	var info FileInfo
+	if err = m.entryCall(ctx, HostCategoryRs, &info, URIStat(srcBucket, srcKey), src); err != nil {
+		return noSuchEntryError(err)
+	}
+	err = m.entryCall(ctx, HostCategoryRs, nil, URIMove(srcBucket, srcKey, destBucket, destKey, force), src, BucketKey{destBucket, destKey})
+	return noSuchEntryError(err)
+}
+
+// noSuchEntryError 将 612 错误转换为 ErrNoSuchEntry，其他错误原样返回
+func noSuchEntryError(err error) error {
+	if errInfo, ok := err.(*ErrorInfo); ok && errInfo.Code == ErrorCodeNoSuchEntry {
+		return ErrNoSuchEntry
+	}
+	return err
+}
+
 // ChangeMime 用来更新文件的MimeType
 func (m *BucketManager) ChangeMime(bucket, key, newMime string) (err error) {
-	reqHost, reqErr := m.RsReqHost(bucket)
-	if reqErr != nil {
-		err = reqErr
-		return
-	}
-	reqURL := fmt.Sprintf("%s%s", reqHost, URIChangeMime(bucket, key, newMime))
-	err = m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil)
+	err = m.entryCall(context.Background(), HostCategoryRs, nil, URIChangeMime(bucket, key, newMime), BucketKey{bucket, key})
 	return
 }
 
 // ChangeType 用来更新文件的存储类型，0 表示普通存储，1 表示低频存储，2 表示归档存储，3 表示深度归档存储
 func (m *BucketManager) ChangeType(bucket, key string, fileType int) (err error) {
-	reqHost, reqErr := m.RsReqHost(bucket)
-	if reqErr != nil {
-		err = reqErr
+	err = m.entryCall(context.Background(), HostCategoryRs, nil, URIChangeType(bucket, key, fileType), BucketKey{bucket, key})
+	return
+}
+
+// ChangeTypeStrict 先查询文件当前的存储类型，文件已经是目标存储类型时返回 ErrSameStorageType，否则修改文件的存储类型
+func (m *BucketManager) ChangeTypeStrict(ctx context.Context, bucket, key string, t StorageType) (err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var info FileInfo
+	if err = m.entryCall(ctx, HostCategoryRs, &info, URIStat(bucket, key), BucketKey{bucket, key}); err != nil {
 		return
 	}
-	reqURL := fmt.Sprintf("%s%s", reqHost, URIChangeType(bucket, key, fileType))
-	err = m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil)
+	if StorageType(info.Type) == t {
+		return ErrSameStorageType
+	}
+	err = m.entryCall(ctx, HostCategoryRs, nil, URIChangeType(bucket, key, int(t)), BucketKey{bucket, key})
+	return
+}
+
+// ChangeTypeIdempotent 修改文件的存储类型，文件已经是目标存储类型时不做任何操作并返回 nil，
+// 需要在存储类型相同时返回错误的可以使用 ChangeTypeStrict
+func (m *BucketManager) ChangeTypeIdempotent(ctx context.Context, bucket, key string, t StorageType) (err error) {
+	if err = m.ChangeTypeStrict(ctx, bucket, key, t); err == ErrSameStorageType {
+		err = nil
+	}
 	return
 }
 
 // RestoreAr 解冻归档存储类型的文件，可设置解冻有效期1～7天, 完成解冻任务通常需要1～5分钟
 func (m *BucketManager) RestoreAr(bucket, key string, freezeAfterDays int) (err error) {
-	reqHost, reqErr := m.RsReqHost(bucket)
-	if reqErr != nil {
-		err = reqErr
+	err = m.entryCall(context.Background(), HostCategoryRs, nil, URIRestoreAr(bucket, key, freezeAfterDays), BucketKey{bucket, key})
+	return
+}
+
+// RestoreArWithExpiry 与 RestoreAr 相同，解冻归档存储类型的文件，并返回解冻有效期结束时间的保守估计。
+// 服务端的 stat 接口不返回解冻的过期时间，有效期从解冻完成时开始计算，因此发起解冻的时间加上 freezeAfterDays 天
+// 不会晚于实际的过期时间，在此之前读取文件是安全的
+func (m *BucketManager) RestoreArWithExpiry(bucket, key string, freezeAfterDays int) (expiresAt time.Time, err error) {
+	start := time.Now()
+	if err = m.RestoreAr(bucket, key, freezeAfterDays); err != nil {
 		return
 	}
-	reqURL := fmt.Sprintf("%s%s", reqHost, URIRestoreAr(bucket, key, freezeAfterDays))
-	err = m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil)
+	expiresAt = start.Add(time.Duration(freezeAfterDays) * 24 * time.Hour)
 	return
 }
 
 // DeleteAfterDays 用来更新文件生命周期，如果 days 设置为0，则表示取消文件的定期删除功能，永久存储
 func (m *BucketManager) DeleteAfterDays(bucket, key string, days int) (err error) {
-	reqHost, reqErr := m.RsReqHost(bucket)
-	if reqErr != nil {
-		err = reqErr
-		return
-	}
-
-	reqURL := fmt.Sprintf("%s%s", reqHost, URIDeleteAfterDays(bucket, key, days))
-	err = m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil)
+	err = m.entryCall(context.Background(), HostCategoryRs, nil, URIDeleteAfterDays(bucket, key, days), BucketKey{bucket, key})
 	return
 }
 
 // Batch 接口提供了资源管理的批量操作，支持 stat，copy，move，delete，chgm，chtype，deleteAfterDays几个接口
 func (m *BucketManager) Batch(operations []string) (batchOpRet []BatchOpRet, err error) {
-	if len(operations) > 1000 {
+	err = m.batch(context.Background(), operations, &batchOpRet)
+	return
+}
+
+// EstimateBatchSize 返回批量操作请求体（表单编码的 op=...&op=...）的大小，单位：字节，
+// 文件名很长时请求体可能超过服务端的限制，可以通过 Config.BatchBodyLimit 提前检查
+func EstimateBatchSize(operations []string) (size int) {
+	for i, op := range operations {
+		if i > 0 {
+			size++ // &
+		}
+		size += len("op=") + len(url.QueryEscape(op))
+	}
+	return
+}
+
+// batch 发送批量操作请求，并将结果解析到 ret 中，ret 必须是指向切片的指针。
+// 请求体超过 Config.BatchBodyLimit 时，开启了 Config.BatchAutoSplit 则拆分为多个请求并按顺序合并结果，否则返回 *BatchBodyTooLargeError
+func (m *BucketManager) batch(ctx context.Context, operations []string, ret interface{}) (err error) {
+	if len(operations) > batchLimit {
 		err = errors.New("batch operation count exceeds the limit of 1000")
 		return
 	}
+	if limit := m.Cfg.BatchBodyLimit; limit > 0 {
+		if size := EstimateBatchSize(operations); size > limit {
+			if !m.Cfg.BatchAutoSplit || len(operations) == 1 {
+				return &BatchBodyTooLargeError{Size: size, Limit: limit}
+			}
+			return m.batchSplit(ctx, operations, ret)
+		}
+	}
 	scheme := "http://"
 	if m.Cfg.UseHTTPS {
 		scheme = "https://"
 	}
-	reqURL := fmt.Sprintf("%s%s/batch", scheme, m.Cfg.CentralRsHost)
+	host, field := m.Cfg.batchHost()
+	reqURL := fmt.Sprintf("%s%s/batch", scheme, host)
 	params := map[string][]string{
 		"op": operations,
 	}
-	err = m.Client.CredentialedCallWithForm(context.Background(), m.Mac, auth.TokenQiniu, &batchOpRet, "POST", reqURL, nil, params)
+	err = m.call(ctx, "batch", "", "", func(ctx context.Context) error {
+		return m.Client.CredentialedCallWithForm(ctx, m.Mac, auth.TokenQiniu, ret, "POST", reqURL, nil, params)
+	})
+	if _, ok := err.(*ErrorInfo); err != nil && !ok && ctx.Err() == nil {
+		return &BatchHostError{Host: host, Field: field, Default: host == DefaultRsHost, Err: err}
+	}
 	return
 }
 
+// batchSplit 将操作拆分为两半分别发送（每一半仍然超过限制时会继续拆分），并按照操作的顺序将结果合并到 ret 中
+func (m *BucketManager) batchSplit(ctx context.Context, operations []string, ret interface{}) error {
+	rv := reflect.ValueOf(ret)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("batch result must be a pointer to slice, got %T", ret)
+	}
+	half := len(operations) / 2
+	for _, ops := range [][]string{operations[:half], operations[half:]} {
+		part := reflect.New(rv.Elem().Type())
+		if err := m.batch(ctx, ops, part.Interface()); err != nil {
+			return err
+		}
+		rv.Elem().Set(reflect.AppendSlice(rv.Elem(), part.Elem()))
+	}
+	return nil
+}
+
 // Fetch 根据提供的远程资源链接来抓取一个文件到空间并已指定文件名保存
+// 抓取总是覆盖已经存在的文件，设置了 Config.RetryPolicy 时遇到 5xx 错误也会重试
 func (m *BucketManager) Fetch(resURL, bucket, key string) (fetchRet FetchRet, err error) {
-	reqHost, rErr := m.IoReqHost(bucket)
-	if rErr != nil {
-		err = rErr
+	err = m.entryCall(overwriteContext(true), HostCategoryIo, &fetchRet, uriFetch(resURL, bucket, key), BucketKey{bucket, key})
+	return
+}
+
+// FetchOpts 同步抓取的可选参数
+type FetchOpts struct {
+	// FileType 抓取后文件的存储类型，取值参见 StorageType，默认为标准存储，不为标准存储时需要额外的请求，参见 FetchWithOpts
+	FileType int
+}
+
+// FetchWithOpts 与 Fetch 相同，从指定 URL 抓取指定名称的资源到空间中，可以通过 opts 指定文件的存储类型。
+// 同步抓取接口不支持直接指定存储类型，FileType 不为标准存储时会在抓取成功后单独发送请求修改文件的存储类型，两个请求不是原子的：
+// 修改失败时文件已经以标准存储写入空间，此时返回抓取结果（fetchRet.Hash 不为空）以及修改存储类型的错误，调用方可以重试 ChangeTypeIdempotent。
+// 需要直接写入指定存储类型时请使用 AsyncFetch 并设置 AsyncFetchParam.FileType
+func (m *BucketManager) FetchWithOpts(resURL, bucket, key string, opts *FetchOpts) (fetchRet FetchRet, err error) {
+	if opts == nil {
+		opts = &FetchOpts{}
+	}
+	if t := StorageType(opts.FileType); !t.valid() {
+		err = fmt.Errorf("invalid file type: %s", t)
 		return
 	}
-	reqURL := fmt.Sprintf("%s%s", reqHost, uriFetch(resURL, bucket, key))
-	err = m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, &fetchRet, "POST", reqURL, nil)
+	if fetchRet, err = m.Fetch(resURL, bucket, key); err != nil || opts.FileType == int(StorageTypeStandard) {
+		return
+	}
+	err = m.ChangeTypeIdempotent(context.Background(), bucket, key, StorageType(opts.FileType))
 	return
 }
 
@@ -476,6 +846,81 @@ func (m *BucketManager) IoReqHost(bucket string) (reqHost string, err error) {
 	return
 }
 
+// HostCategory 为 Do 方法请求的服务类型，用于决定请求发送到哪一类域名
+type HostCategory string
+
+const (
+	HostCategoryRs  HostCategory = "rs"
+	HostCategoryRsf HostCategory = "rsf"
+	HostCategoryIo  HostCategory = "io"
+	HostCategoryApi HostCategory = "api"
+	HostCategoryUc  HostCategory = "uc"
+)
+
+// ReqHostByCategory 根据服务类型获取请求的域名
+// bucket 不为空时，使用空间所在区域的域名；为空时使用 Config 中配置的域名或者默认域名
+func (m *BucketManager) ReqHostByCategory(hostCategory HostCategory, bucket string) (reqHost string, err error) {
+	switch hostCategory {
+	case HostCategoryRs:
+		if bucket != "" {
+			return m.RsReqHost(bucket)
+		}
+		reqHost = m.Cfg.RsReqHost()
+	case HostCategoryRsf:
+		if bucket != "" {
+			return m.RsfReqHost(bucket)
+		}
+		reqHost = m.Cfg.RsfReqHost()
+	case HostCategoryApi:
+		if bucket != "" {
+			return m.ApiReqHost(bucket)
+		}
+		reqHost = m.Cfg.ApiReqHost()
+	case HostCategoryIo:
+		if bucket != "" || m.Cfg.IoHost != "" {
+			return m.IoReqHost(bucket)
+		}
+		err = errors.New("bucket or Config.IoHost is required for io host")
+	case HostCategoryUc:
+		reqHost = m.Cfg.UcReqHost()
+	default:
+		err = fmt.Errorf("unknown host category: %s", hostCategory)
+	}
+	return
+}
+
+// Do 发送一个经过七牛鉴权的请求到指定类型的服务，用于调用 SDK 尚未封装的接口
+//
+// hostCategory 决定请求发送到 rs，rsf，io，api，uc 中的哪一类域名，bucket 可以为空，参考 ReqHostByCategory；
+// path 为请求路径，可以带有查询参数；body 为 nil 时不发送请求体，为 map[string][]string 或 url.Values 时
+// 以表单形式发送，其他类型以 JSON 形式发送；ret 不为 nil 时将响应的 JSON 解析到 ret 中。
+//
+// 该方法属于高级接口，其行为在后续版本中可能会发生变化
+func (m *BucketManager) Do(ctx context.Context, method string, hostCategory HostCategory, bucket, path string, body interface{}, ret interface{}) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	reqHost, err := m.ReqHostByCategory(hostCategory, bucket)
+	if err != nil {
+		return err
+	}
+	reqURL := strings.TrimRight(reqHost, "/") + "/" + strings.TrimLeft(path, "/")
+	ret = m.jsonRet(ret)
+
+	return m.call(ctx, operationName(path), bucket, "", func(ctx context.Context) error {
+		switch b := body.(type) {
+		case nil:
+			return m.Client.CredentialedCall(ctx, m.Mac, auth.TokenQiniu, ret, method, reqURL, nil)
+		case url.Values:
+			return m.Client.CredentialedCallWithForm(ctx, m.Mac, auth.TokenQiniu, ret, method, reqURL, nil, b)
+		case map[string][]string:
+			return m.Client.CredentialedCallWithForm(ctx, m.Mac, auth.TokenQiniu, ret, method, reqURL, nil, b)
+		default:
+			return m.Client.CredentialedCallWithJson(ctx, m.Mac, auth.TokenQiniu, ret, method, reqURL, nil, b)
+		}
+	})
+}
+
 // FetchWithoutKey 根据提供的远程资源链接来抓取一个文件到空间并以文件的内容hash作为文件名
 func (m *BucketManager) FetchWithoutKey(resURL, bucket string) (fetchRet FetchRet, err error) {
 	reqHost, rErr := m.IoReqHost(bucket)
@@ -488,6 +933,26 @@ func (m *BucketManager) FetchWithoutKey(resURL, bucket string) (fetchRet FetchRe
 	return
 }
 
+// FetchReader 将 r 中的内容以表单方式上传到空间中并保存为 key，已经存在的同名文件会被覆盖，size 为内容的大小，未知时可以传 0。
+// 与 Fetch 从远程链接抓取不同，该方法用于上传本地的数据，上传完成后通过 Stat 返回文件信息
+func (m *BucketManager) FetchReader(ctx context.Context, bucket, key string, r io.Reader, size int64) (info FileInfo, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err = m.checkEntries(BucketKey{bucket, key}); err != nil {
+		return
+	}
+	putPolicy := PutPolicy{
+		Scope: fmt.Sprintf("%s:%s", bucket, key),
+	}
+	upToken := putPolicy.UploadToken(m.Mac)
+	var putRet PutRet
+	if err = NewFormUploaderEx(m.Cfg, m.Client).Put(ctx, &putRet, upToken, key, r, size, &PutExtra{}); err != nil {
+		return
+	}
+	return m.Stat(bucket, key)
+}
+
 // DomainInfo 是绑定在存储空间上的域名的具体信息
 type DomainInfo struct {
 	Domain string `json:"domain"`
@@ -515,13 +980,7 @@ func (m *BucketManager) ListBucketDomains(bucket string) (info []DomainInfo, err
 
 // Prefetch 用来同步镜像空间的资源和镜像源资源内容
 func (m *BucketManager) Prefetch(bucket, key string) (err error) {
-	reqHost, reqErr := m.IoReqHost(bucket)
-	if reqErr != nil {
-		err = reqErr
-		return
-	}
-	reqURL := fmt.Sprintf("%s%s", reqHost, uriPrefetch(bucket, key))
-	err = m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil)
+	err = m.entryCall(context.Background(), HostCategoryIo, nil, uriPrefetch(bucket, key), BucketKey{bucket, key})
 	return
 }
 
@@ -529,7 +988,7 @@ func (m *BucketManager) Prefetch(bucket, key string) (err error) {
 func (m *BucketManager) SetImage(siteURL, bucket string) (err error) {
 	reqURL := fmt.Sprintf("http://%s%s", DefaultPubHost, uriSetImage(siteURL, bucket))
 	err = m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil)
-	return
+	return m.bucketInfoChanged(bucket, err)
 }
 
 // SetImageWithHost 用来设置空间镜像源，额外添加回源Host头部
@@ -537,19 +996,24 @@ func (m *BucketManager) SetImageWithHost(siteURL, bucket, host string) (err erro
 	reqURL := fmt.Sprintf("http://%s%s", DefaultPubHost,
 		uriSetImageWithHost(siteURL, bucket, host))
 	err = m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil)
-	return
+	return m.bucketInfoChanged(bucket, err)
 }
 
 // UnsetImage 用来取消空间镜像源设置
 func (m *BucketManager) UnsetImage(bucket string) (err error) {
 	reqURL := fmt.Sprintf("http://%s%s", DefaultPubHost, uriUnsetImage(bucket))
 	err = m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil)
-	return err
+	return m.bucketInfoChanged(bucket, err)
 }
 
 // ListFiles 用来获取空间文件列表，可以根据需要指定文件的前缀 prefix，文件的目录 delimiter，循环列举的时候下次
 // 列举的位置 marker，以及每次返回的文件的最大数量limit，其中limit最大为1000。
 func (m *BucketManager) ListFiles(bucket, prefix, delimiter, marker string,
+	limit int) (entries []ListItem, commonPrefixes []string, nextMarker string, hasNext bool, err error) {
+	return m.listFiles(context.Background(), bucket, prefix, delimiter, marker, limit)
+}
+
+func (m *BucketManager) listFiles(ctx context.Context, bucket, prefix, delimiter, marker string,
 	limit int) (entries []ListItem, commonPrefixes []string, nextMarker string, hasNext bool, err error) {
 	if limit <= 0 || limit > 1000 {
 		err = errors.New("invalid list limit, only allow [1, 1000]")
@@ -564,7 +1028,9 @@ func (m *BucketManager) ListFiles(bucket, prefix, delimiter, marker string,
 
 	ret := listFilesRet{}
 	reqURL := fmt.Sprintf("%s%s", reqHost, uriListFiles(bucket, prefix, delimiter, marker, limit))
-	err = m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, &ret, "POST", reqURL, nil)
+	err = m.call(ctx, "list", bucket, "", func(ctx context.Context) error {
+		return m.Client.CredentialedCall(ctx, m.Mac, auth.TokenQiniu, m.jsonRet(&ret), "POST", reqURL, nil)
+	})
 	if err != nil {
 		return
 	}
@@ -579,7 +1045,27 @@ func (m *BucketManager) ListFiles(bucket, prefix, delimiter, marker string,
 	return
 }
 
+// CheckBucketAccess 通过列举一个文件检查当前的凭证是否可以访问空间，可以在执行耗时的任务前预先检查。
+// 空间不存在时返回 ErrNoSuchBucket，没有权限时返回 ErrAccessDenied，其他错误原样返回
+func (m *BucketManager) CheckBucketAccess(ctx context.Context, bucket string) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	_, _, _, _, err := m.listFiles(ctx, bucket, "", "", "", 1)
+	if errInfo, ok := err.(*ErrorInfo); ok {
+		switch errInfo.Code {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return ErrAccessDenied
+		case ErrorCodeNoSuchBucket:
+			return ErrNoSuchBucket
+		}
+	}
+	return err
+}
+
 // ListBucket 用来获取空间文件列表，可以根据需要指定文件的前缀 prefix，文件的目录 delimiter，流式返回每条数据。
+// 响应解析失败（如连接中断）时最后一条数据的 Err 不为空，此时列举结果不完整。
+// 服务端按照 Key 的字典序返回文件，指定了 delimiter 时目录项（Dir）会穿插在文件之间返回，如果需要确定的顺序，请使用 ListBucketSorted
 func (m *BucketManager) ListBucket(bucket, prefix, delimiter, marker string) (retCh chan listFilesRet2, err error) {
 
 	ctx := auth.WithCredentialsType(context.Background(), m.Mac, auth.TokenQiniu)
@@ -591,13 +1077,26 @@ func (m *BucketManager) ListBucket(bucket, prefix, delimiter, marker string) (re
 
 	// limit 0 ==> 列举所有文件
 	reqURL := fmt.Sprintf("%s%s", reqHost, uriListFiles2(bucket, prefix, delimiter, marker))
-	retCh, err = callChan(m.Client, ctx, "POST", reqURL, nil)
+	retCh, err = callChan(m.Client, ctx, "POST", reqURL, nil, m.Cfg.UseJSONNumber)
 	return
 }
 
 // ListBucketContext 用来获取空间文件列表，可以根据需要指定文件的前缀 prefix，文件的目录 delimiter，流式返回每条数据。
-// 接受的context可以用来取消列举操作
+// 接受的context可以用来取消列举操作，不再读取 retCh 时需要取消 ctx，后台 goroutine 会退出并关闭连接
+// 返回顺序与 ListBucket 相同
 func (m *BucketManager) ListBucketContext(ctx context.Context, bucket, prefix, delimiter, marker string) (retCh chan listFilesRet2, err error) {
+	return m.ListBucketWithOpts(ctx, bucket, prefix, delimiter, marker, nil)
+}
+
+// ListBucketOpts 流式列举的可选参数
+type ListBucketOpts struct {
+	// NeedParts 为 true 时请求服务端在每个文件中返回分片信息 ListItem.Parts，用于批量检查分片上传的文件，
+	// 会明显增加响应的大小，服务端不支持时 Parts 为空
+	NeedParts bool
+}
+
+// ListBucketWithOpts 与 ListBucketContext 相同，但可以通过 opts 指定可选参数，opts 为 nil 时与 ListBucketContext 完全一致
+func (m *BucketManager) ListBucketWithOpts(ctx context.Context, bucket, prefix, delimiter, marker string, opts *ListBucketOpts) (retCh chan listFilesRet2, err error) {
 
 	ctx = auth.WithCredentialsType(ctx, m.Mac, auth.TokenQiniu)
 	reqHost, reqErr := m.RsfReqHost(bucket)
@@ -608,10 +1107,154 @@ func (m *BucketManager) ListBucketContext(ctx context.Context, bucket, prefix, d
 
 	// limit 0 ==> 列举所有文件
 	reqURL := fmt.Sprintf("%s%s", reqHost, uriListFiles2(bucket, prefix, delimiter, marker))
-	retCh, err = callChan(m.Client, ctx, "POST", reqURL, nil)
+	if opts != nil && opts.NeedParts {
+		reqURL += "&needparts=true"
+	}
+	retCh, err = callChan(m.Client, ctx, "POST", reqURL, nil, m.Cfg.UseJSONNumber)
+	return
+}
+
+// ListFlat 递归地流式返回空间中以 prefix 开头的所有文件，总是使用空的 delimiter，只返回文件，不返回目录，返回的每一项 Item 都不为 nil。
+// 与 ListBucketContext 指定 delimiter 为 "/" 时只列举一层不同，子目录中的文件同样会被返回。
+// 列举中断时最后一项的 Err 不为空，此时 Item 为 nil
+func (m *BucketManager) ListFlat(ctx context.Context, bucket, prefix string) (retCh chan ListEntry, err error) {
+	srcCh, err := m.ListBucketContext(ctx, bucket, prefix, "", "")
+	if err != nil {
+		return
+	}
+
+	retCh = make(chan ListEntry)
+	go func() {
+		defer close(retCh)
+		for ret := range srcCh {
+			entry := ListEntry{Marker: ret.Marker, Err: ret.Err}
+			if ret.Err == nil {
+				if ret.Dir != "" || ret.Item.Key == "" {
+					continue
+				}
+				item := ret.Item
+				entry.Item = &item
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case retCh <- entry:
+			}
+		}
+	}()
 	return
 }
 
+// ListEntry 流式列举结果中的一项，Item 和 Dir 有且只有一个有效：文件时 Item 不为 nil，目录时 Dir 不为空。
+// Err 在无法继续列举（如连接中断、ListBucketResilient 重试失败）时设置，此时该项为最后一项，Item 为 nil 并且 Dir 为空，
+// ListBucketResilient 返回的 Marker 为最后一个成功返回的位置
+type ListEntry struct {
+	Marker string
+	Item   *ListItem
+	Dir    string
+	Err    error
+}
+
+// IsDir 判断该项是否为目录
+func (e *ListEntry) IsDir() bool {
+	return e.Item == nil && e.Dir != ""
+}
+
+// ListBucketEntries 与 ListBucketContext 相同，流式返回空间文件列表，但明确区分文件和目录，
+// 文件和目录按照服务端返回的顺序交错返回，适合根据 delimiter 构建目录树的场景，列举中断时最后一项的 Err 不为空
+func (m *BucketManager) ListBucketEntries(ctx context.Context, bucket, prefix, delimiter, marker string) (retCh chan ListEntry, err error) {
+	srcCh, err := m.ListBucketContext(ctx, bucket, prefix, delimiter, marker)
+	if err != nil {
+		return
+	}
+
+	retCh = make(chan ListEntry)
+	go func() {
+		defer close(retCh)
+		for ret := range srcCh {
+			entry := ListEntry{Marker: ret.Marker, Err: ret.Err}
+			switch {
+			case ret.Err != nil:
+				// 列举中断，该项为最后一项
+			case ret.Dir != "":
+				entry.Dir = ret.Dir
+			case ret.Item.Key != "":
+				item := ret.Item
+				entry.Item = &item
+			default:
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case retCh <- entry:
+			}
+		}
+	}()
+	return
+}
+
+// ListBucketSorted 与 ListBucketContext 相同，流式返回空间文件列表，但会在本地以 pageSize 条为一页缓存数据，
+// 并将页内的文件和目录按照名称（文件为 Key，目录为 Dir）排序后再返回，以内存换取页内确定的顺序。
+// pageSize <= 0 时使用默认值 1000。
+// 注意排序后页内的 Marker 不再保证单调，如果需要从中断处继续列举，请使用 ListBucketContext
+func (m *BucketManager) ListBucketSorted(ctx context.Context, bucket, prefix, delimiter, marker string, pageSize int) (retCh chan listFilesRet2, err error) {
+	if pageSize <= 0 {
+		pageSize = 1000
+	}
+
+	srcCh, err := m.ListBucketContext(ctx, bucket, prefix, delimiter, marker)
+	if err != nil {
+		return
+	}
+
+	retCh = make(chan listFilesRet2)
+	go func() {
+		defer close(retCh)
+
+		page := make([]listFilesRet2, 0, pageSize)
+		flush := func() bool {
+			sort.SliceStable(page, func(i, j int) bool {
+				return page[i].name() < page[j].name()
+			})
+			for _, ret := range page {
+				select {
+				case <-ctx.Done():
+					return false
+				case retCh <- ret:
+				}
+			}
+			page = page[:0]
+			return true
+		}
+
+		for ret := range srcCh {
+			if ret.Err != nil {
+				// 列举中断时先返回已经缓存的数据，再返回错误
+				if flush() {
+					select {
+					case <-ctx.Done():
+					case retCh <- ret:
+					}
+				}
+				return
+			}
+			page = append(page, ret)
+			if len(page) >= pageSize && !flush() {
+				return
+			}
+		}
+		flush()
+	}()
+	return
+}
+
+// AsyncFetchParam.CallbackBodyType 的取值
+const (
+	CallbackBodyTypeForm = "application/x-www-form-urlencoded"
+	CallbackBodyTypeJSON = "application/json"
+)
+
 type AsyncFetchParam struct {
 	Url              string `json:"url"`
 	Host             string `json:"host,omitempty"`
@@ -625,27 +1268,116 @@ type AsyncFetchParam struct {
 	FileType         int    `json:"file_type,omitempty"`
 }
 
+// AsyncFetchRet 异步抓取任务的信息
 type AsyncFetchRet struct {
-	Id   string `json:"id"`
-	Wait int    `json:"wait"`
+	Id string `json:"id"`
+
+	// Wait 服务端建议的查询任务状态前的等待时间，单位：秒，小于 0 时表示任务已经被处理
+	Wait int `json:"wait"`
 }
 
+// ShouldRetryAfter 将 Wait 转换为 time.Duration，即查询任务状态前需要等待的时间，Wait 小于等于 0 时返回 0
+func (r *AsyncFetchRet) ShouldRetryAfter() time.Duration {
+	if r.Wait <= 0 {
+		return 0
+	}
+	return time.Duration(r.Wait) * time.Second
+}
+
+// AsyncFetch 发起异步抓取任务，CallbackBodyType 会去掉首尾的空白字符并转换为小写，
+// 只能为空、CallbackBodyTypeForm 或 CallbackBodyTypeJSON，否则返回错误
 func (m *BucketManager) AsyncFetch(param AsyncFetchParam) (ret AsyncFetchRet, err error) {
+	param.CallbackBodyType = strings.ToLower(strings.TrimSpace(param.CallbackBodyType))
+	switch param.CallbackBodyType {
+	case "", CallbackBodyTypeForm, CallbackBodyTypeJSON:
+	default:
+		err = fmt.Errorf("invalid callback body type: %q", param.CallbackBodyType)
+		return
+	}
+	return m.AsyncFetchRaw(context.Background(), param.Bucket, param)
+}
+
+// AsyncFetchRaw 发起异步抓取任务，body 会被序列化为 JSON 作为请求体，可以用于传递 AsyncFetchParam 中尚未定义的字段，
+// 已经序列化的 JSON 可以使用 json.RawMessage 传递。字段名称和取值需要调用方保证正确，参见
+// https://developer.qiniu.com/kodo/api/4097/asynch-fetch ，bucket 用于确定请求的域名，需要与 body 中的 bucket 一致。
+// 与其他管理接口相同，请求会记录到 Config.Tracer，并按照 Config.RetryPolicy 在限流时重试
+func (m *BucketManager) AsyncFetchRaw(ctx context.Context, bucket string, body interface{}) (ret AsyncFetchRet, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if body == nil {
+		err = errors.New("async fetch body is nil")
+		return
+	}
 
-	reqUrl, err := m.ApiReqHost(param.Bucket)
+	reqUrl, err := m.ApiReqHost(bucket)
 	if err != nil {
 		return
 	}
 
 	reqUrl += "/sisyphus/fetch"
 
-	err = m.Client.CredentialedCallWithJson(context.Background(), m.Mac, auth.TokenQiniu, &ret, "POST", reqUrl, nil, param)
+	err = m.call(ctx, "asyncFetch", bucket, "", func(ctx context.Context) error {
+		return m.Client.CredentialedCallWithJson(ctx, m.Mac, auth.TokenQiniu, &ret, "POST", reqUrl, nil, body)
+	})
 	return
 }
 
+// AsyncFetchStatus 查询异步抓取任务 id 的状态，bucket 用于确定请求的域名，与 AsyncFetchRaw 相同会记录到 Config.Tracer 并在限流时重试
+func (m *BucketManager) AsyncFetchStatus(ctx context.Context, bucket, id string) (ret AsyncFetchRet, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	reqUrl, err := m.ApiReqHost(bucket)
+	if err != nil {
+		return
+	}
+	reqUrl += "/sisyphus/fetch?id=" + url.QueryEscape(id)
+	err = m.call(ctx, "asyncFetchStatus", bucket, "", func(ctx context.Context) error {
+		return m.Client.CredentialedCall(ctx, m.Mac, auth.TokenQiniu, &ret, "GET", reqUrl, nil)
+	})
+	return
+}
+
+// asyncFetchPollInterval、asyncFetchMaxPollInterval WaitAsyncFetch 查询任务状态的初始间隔以及最长间隔
+var (
+	asyncFetchPollInterval    = time.Second
+	asyncFetchMaxPollInterval = 30 * time.Second
+)
+
+// WaitAsyncFetch 等待 AsyncFetch 返回的任务 ret 被处理，返回最后一次查询的结果。
+// 第一次查询前按照 ret.ShouldRetryAfter() 等待（为 0 时等待 asyncFetchPollInterval），之后每次查询的间隔翻倍，
+// 最长为 asyncFetchMaxPollInterval，查询结果的 Wait 小于 0 时表示任务已经被处理。
+// 任务被处理不代表抓取成功，抓取结果需要通过回调或者 Stat 确认，ctx 取消时返回 ctx.Err()
+func (m *BucketManager) WaitAsyncFetch(ctx context.Context, bucket string, ret AsyncFetchRet) (status AsyncFetchRet, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	delay := ret.ShouldRetryAfter()
+	if delay <= 0 {
+		delay = asyncFetchPollInterval
+	}
+	for {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return status, ctx.Err()
+		case <-timer.C:
+		}
+		if status, err = m.AsyncFetchStatus(ctx, bucket, ret.Id); err != nil || status.Wait < 0 {
+			return
+		}
+		if delay *= 2; delay > asyncFetchMaxPollInterval {
+			delay = asyncFetchMaxPollInterval
+		}
+	}
+}
+
 func (m *BucketManager) RsHost(bucket string) (rsHost string, err error) {
 	zone, err := m.Zone(bucket)
 	if err != nil {
+		err = zoneHostError(err, "RsHost")
 		return
 	}
 
@@ -656,6 +1388,7 @@ func (m *BucketManager) RsHost(bucket string) (rsHost string, err error) {
 func (m *BucketManager) RsfHost(bucket string) (rsfHost string, err error) {
 	zone, err := m.Zone(bucket)
 	if err != nil {
+		err = zoneHostError(err, "RsfHost")
 		return
 	}
 
@@ -666,6 +1399,7 @@ func (m *BucketManager) RsfHost(bucket string) (rsfHost string, err error) {
 func (m *BucketManager) IovipHost(bucket string) (iovipHost string, err error) {
 	zone, err := m.Zone(bucket)
 	if err != nil {
+		err = zoneHostError(err, "IoHost")
 		return
 	}
 
@@ -676,6 +1410,7 @@ func (m *BucketManager) IovipHost(bucket string) (iovipHost string, err error) {
 func (m *BucketManager) ApiHost(bucket string) (apiHost string, err error) {
 	zone, err := m.Zone(bucket)
 	if err != nil {
+		err = zoneHostError(err, "ApiHost")
 		return
 	}
 
@@ -694,11 +1429,23 @@ func (m *BucketManager) Zone(bucket string) (z *Zone, err error) {
 		z = m.Cfg.Zone
 		return
 	}
+	if m.Cfg.DisableZoneDiscovery {
+		err = ErrZoneDiscoveryDisabled
+		return
+	}
 
 	z, err = GetZone(m.Mac.AccessKey, bucket)
 	return
 }
 
+// zoneHostError 在禁用了区域自动查询时返回指明需要配置哪个 Host 的错误
+func zoneHostError(err error, hostName string) error {
+	if err == ErrZoneDiscoveryDisabled {
+		return fmt.Errorf("%s, Config.%s or Config.Zone is required", err, hostName)
+	}
+	return err
+}
+
 // 构建op的方法，导出的方法支持在Batch操作中使用
 
 // URIStat 构建 stat 接口的请求命令
@@ -717,6 +1464,18 @@ func URICopy(srcBucket, srcKey, destBucket, destKey string, force bool) string {
 		EncodedEntry(destBucket, destKey), force)
 }
 
+// URICopyWithOpts 构建带可选参数的 copy 接口的请求命令
+func URICopyWithOpts(srcBucket, srcKey, destBucket, destKey string, opts *CopyOpts) string {
+	if opts == nil {
+		opts = &CopyOpts{}
+	}
+	uri := URICopy(srcBucket, srcKey, destBucket, destKey, opts.Force)
+	if opts.EndUser != "" {
+		uri += fmt.Sprintf("/endUser/%s", base64.URLEncoding.EncodeToString([]byte(opts.EndUser)))
+	}
+	return uri
+}
+
 // URIMove 构建 move 接口的请求命令
 func URIMove(srcBucket, srcKey, destBucket, destKey string, force bool) string {
 	return fmt.Sprintf("/move/%s/%s/force/%v", EncodedEntry(srcBucket, srcKey),
@@ -744,6 +1503,41 @@ func URIRestoreAr(bucket, key string, afterDay int) string {
 	return fmt.Sprintf("/restoreAr/%s/freezeAfterDays/%d", EncodedEntry(bucket, key), afterDay)
 }
 
+// URITag 构建设置文件标签的请求命令。七牛没有独立的文件标签，标签保存为文件的自定义元数据 x-qn-meta-<name>，
+// 通过 chgm 接口设置，已有的同名元数据会被覆盖。
+// 标签名只能包含字母、数字、下划线和中划线，值可以是任意字符串，在构建前可以使用 ValidateTags 检查
+func URITag(bucket, key string, tags map[string]string) string {
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	uri := fmt.Sprintf("/chgm/%s", EncodedEntry(bucket, key))
+	for _, name := range names {
+		uri += fmt.Sprintf("/x-qn-meta-%s/%s", name, base64.URLEncoding.EncodeToString([]byte(tags[name])))
+	}
+	return uri
+}
+
+// ValidateTags 检查 URITag 的标签名是否合法，标签名不能为空，只能包含字母、数字、下划线和中划线
+func ValidateTags(tags map[string]string) error {
+	if len(tags) == 0 {
+		return errors.New("tags is empty")
+	}
+	for name := range tags {
+		if name == "" {
+			return errors.New("tag name is empty")
+		}
+		for _, c := range name {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return fmt.Errorf("invalid character %q in tag name %q", c, name)
+			}
+		}
+	}
+	return nil
+}
+
 // 构建op的方法，非导出的方法无法用在Batch操作中
 func uriFetch(resURL, bucket, key string) string {
 	return fmt.Sprintf("/fetch/%s/to/%s",
@@ -755,6 +1549,11 @@ func uriFetchWithoutKey(resURL, bucket string) string {
 		base64.URLEncoding.EncodeToString([]byte(resURL)), EncodedEntryWithoutKey(bucket))
 }
 
+func uriDeleteIfHash(bucket, key, hash string) string {
+	cond := "hash=" + hash
+	return fmt.Sprintf("%s/cond/%s", URIDelete(bucket, key), base64.URLEncoding.EncodeToString([]byte(cond)))
+}
+
 func uriPrefetch(bucket, key string) string {
 	return fmt.Sprintf("/prefetch/%s", EncodedEntry(bucket, key))
 }
@@ -818,6 +1617,26 @@ func EncodedEntryWithoutKey(bucket string) string {
 	return base64.URLEncoding.EncodeToString([]byte(bucket))
 }
 
+// EncodedEntryNoPad 生成不带填充字符 "=" 的 URL Safe Base64编码的Entry，用于不接受填充字符的场景
+func EncodedEntryNoPad(bucket, key string) string {
+	entry := fmt.Sprintf("%s:%s", bucket, key)
+	return base64.RawURLEncoding.EncodeToString([]byte(entry))
+}
+
+// DecodeEntry 解析 EncodedEntry、EncodedEntryNoPad 或 EncodedEntryWithoutKey 生成的Entry，返回空间名和文件名
+// 空间名中不会出现 ":"，因此以第一个 ":" 分隔空间名和文件名，没有 ":" 时 key 为空
+func DecodeEntry(encodedEntry string) (bucket, key string, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encodedEntry, "="))
+	if err != nil {
+		return
+	}
+	entry := string(data)
+	if i := strings.Index(entry, ":"); i >= 0 {
+		return entry[:i], entry[i+1:], nil
+	}
+	return entry, "", nil
+}
+
 // MakePublicURL 用来生成公开空间资源下载链接，注意该方法并不会对 key 进行 escape
 func MakePublicURL(domain, key string) (finalUrl string) {
 	domain = strings.TrimRight(domain, "/")
@@ -847,15 +1666,43 @@ func makePublicURLv2WithQueryString(domain, key, query string) string {
 }
 
 func makePublicURLv2WithRawQuery(domain, key, rawQuery string) string {
+	return makePublicURL(domain, key, rawQuery, nil)
+}
+
+func makePublicURL(domain, key, rawQuery string, policy *EscapePolicy) string {
+	if policy == nil {
+		policy = DefaultEscapePolicy
+	}
 	domain = strings.TrimRight(domain, "/")
-	srcUrl := fmt.Sprintf("%s/%s", domain, urlEncodeQuery(key))
+	srcUrl := fmt.Sprintf("%s/%s", domain, policy.escape(key))
 	if rawQuery != "" {
 		srcUrl += "?" + rawQuery
 	}
+	if !policy.normalize {
+		// 严格按照 policy 生成，url.Parse 会按照自己的规则重新 escape 路径
+		return srcUrl
+	}
 	srcUri, _ := url.Parse(srcUrl)
 	return srcUri.String()
 }
 
+// Deadline 返回从现在开始 ttl 之后的时间点，即 MakePrivateURL 等方法的 deadline 参数，单位为秒级 Unix 时间戳，例如：
+//
+//	// 生成有效期为 1 小时的私有链接
+//	privateURL := storage.MakePrivateURLv2(mac, domain, key, storage.Deadline(time.Hour))
+func Deadline(ttl time.Duration) int64 {
+	return DeadlineAt(time.Now().Add(ttl))
+}
+
+// DeadlineAt 返回时间点 t 对应的 MakePrivateURL 等方法的 deadline 参数，单位为秒级 Unix 时间戳（不是毫秒），例如：
+//
+//	// 生成在今天结束时过期的私有链接
+//	year, month, day := time.Now().Date()
+//	privateURL := storage.MakePrivateURLv2(mac, domain, key, storage.DeadlineAt(time.Date(year, month, day+1, 0, 0, 0, 0, time.Local)))
+func DeadlineAt(t time.Time) int64 {
+	return t.Unix()
+}
+
 // MakePrivateURL 用来生成私有空间资源下载链接，注意该方法并不会对 key 进行 escape
 func MakePrivateURL(mac *auth.Credentials, domain, key string, deadline int64) (privateURL string) {
 	publicURL := MakePublicURL(domain, key)
@@ -889,14 +1736,84 @@ func MakePrivateURLv2WithQueryString(mac *auth.Credentials, domain, key, query s
 	return makePrivateURLv2WithRawQuery(mac, domain, key, urlEncodeQuery(query), deadline)
 }
 
-func makePrivateURLv2WithRawQuery(mac *auth.Credentials, domain, key, rawQuery string, deadline int64) (privateURL string) {
-	publicURL := makePublicURLv2WithRawQuery(domain, key, rawQuery)
-	urlToSign := publicURL
+// MakePrivateURLWithExpiry 与 MakePrivateURLv2 相同，生成有效期为 ttl 的私有下载链接，同时返回链接的过期时间，
+// expiresAt 与链接中的 e 参数一致（精确到秒），缓存链接时可以据此在过期前重新生成
+func MakePrivateURLWithExpiry(mac *auth.Credentials, domain, key string, ttl time.Duration) (privateURL string, expiresAt time.Time, err error) {
+	if mac == nil {
+		err = errors.New("mac is nil")
+		return
+	}
+	if ttl <= 0 {
+		err = errors.New("ttl must be greater than 0")
+		return
+	}
+	deadline := Deadline(ttl)
+	return MakePrivateURLv2(mac, domain, key, deadline), time.Unix(deadline, 0), nil
+}
+
+// MakePrivateURLWithPathPrefix 用来生成通过 CDN 路径前缀访问的私有下载链接，CDN 将 domain/pathPrefix/<key> 回源到 key 时使用，
+// 签名覆盖的是实际访问的路径，即 domain + "/" + pathPrefix + "/" + escape 之后的 key，pathPrefix 首尾的 / 会被忽略且不会被 escape。
+// 签名同样覆盖查询参数，e 和 token 总是追加在最后，签名之后再追加的查询参数会导致校验失败，
+// 需要查询参数时可以将 domain + "/" + pathPrefix 作为 domain 调用 MakePrivateURLv2WithQuery
+func MakePrivateURLWithPathPrefix(mac *auth.Credentials, domain, pathPrefix, key string, deadline int64) (privateURL string) {
+	domain = strings.TrimRight(domain, "/")
+	if pathPrefix = strings.Trim(pathPrefix, "/"); pathPrefix != "" {
+		domain += "/" + pathPrefix
+	}
+	return makePrivateURLv2WithRawQuery(mac, domain, key, "", deadline)
+}
+
+// MakePrivateURLsWithDeadlines 批量生成私有空间资源下载链接，每个文件使用各自的过期时间，返回文件名到下载链接的映射
+func MakePrivateURLsWithDeadlines(mac *auth.Credentials, domain string, keyDeadlines map[string]int64) (privateURLs map[string]string) {
+	domain = strings.TrimRight(domain, "/")
+	privateURLs = make(map[string]string, len(keyDeadlines))
+	for key, deadline := range keyDeadlines {
+		privateURLs[key] = makePrivateURLv2WithRawQuery(mac, domain, key, "", deadline)
+	}
+	return
+}
+
+// URLRequest MakeURLs 的一个请求，Private 为 true 时生成私有链接，否则生成公开链接
+type URLRequest struct {
+	Key     string
+	Private bool
+}
+
+// MakeURLs 批量生成下载链接，返回的链接与 items 一一对应：Private 为 true 的文件使用 mac 签名生成有效期到 deadline 的私有链接，
+// 参见 MakePrivateURLv2，其他文件生成公开链接，参见 MakePublicURLv2，key 都会被 escape
+func MakeURLs(mac *auth.Credentials, domain string, items []URLRequest, deadline int64) (urls []string) {
+	domain = strings.TrimRight(domain, "/")
+	urls = make([]string, len(items))
+	for i, item := range items {
+		if item.Private {
+			urls[i] = makePrivateURLv2WithRawQuery(mac, domain, item.Key, "", deadline)
+		} else {
+			urls[i] = makePublicURLv2WithRawQuery(domain, item.Key, "")
+		}
+	}
+	return
+}
+
+// CanonicalSignString 返回 MakePrivateURLv2WithQuery 等方法在追加 token 之前实际签名的字符串，即追加了 e 参数的下载链接，
+// rawQuery 为已经编码的查询参数。该方法仅用于调试，如排查 CDN 配置错误导致的签名不一致
+func CanonicalSignString(domain, key, rawQuery string, deadline int64) string {
+	return canonicalSignString(domain, key, rawQuery, deadline, nil)
+}
+
+func canonicalSignString(domain, key, rawQuery string, deadline int64, policy *EscapePolicy) string {
+	publicURL := makePublicURL(domain, key, rawQuery, policy)
 	if strings.Contains(publicURL, "?") {
-		urlToSign = fmt.Sprintf("%s&e=%d", urlToSign, deadline)
-	} else {
-		urlToSign = fmt.Sprintf("%s?e=%d", urlToSign, deadline)
+		return fmt.Sprintf("%s&e=%d", publicURL, deadline)
 	}
+	return fmt.Sprintf("%s?e=%d", publicURL, deadline)
+}
+
+func makePrivateURLv2WithRawQuery(mac *auth.Credentials, domain, key, rawQuery string, deadline int64) (privateURL string) {
+	return makePrivateURL(mac, domain, key, rawQuery, deadline, nil)
+}
+
+func makePrivateURL(mac *auth.Credentials, domain, key, rawQuery string, deadline int64, policy *EscapePolicy) (privateURL string) {
+	urlToSign := canonicalSignString(domain, key, rawQuery, deadline, policy)
 	token := mac.Sign([]byte(urlToSign))
 	privateURL = fmt.Sprintf("%s&token=%s", urlToSign, token)
 	return
@@ -914,6 +1831,17 @@ type listFilesRet2 struct {
 	Marker string   `json:"marker"`
 	Item   ListItem `json:"item"`
 	Dir    string   `json:"dir"`
+
+	// Err 解析响应失败（如连接中断）时设置，此时该项为最后一项，其他字段都为空，列举结果不完整
+	Err error `json:"-"`
+}
+
+// name 返回列举结果的名称，目录返回 Dir，文件返回 Key
+func (r *listFilesRet2) name() string {
+	if r.Dir != "" {
+		return r.Dir
+	}
+	return r.Item.Key
 }
 
 type listFilesRet struct {
@@ -931,6 +1859,9 @@ type ListItem struct {
 	MimeType string `json:"mimeType"`
 	Type     int    `json:"type"`
 	EndUser  string `json:"endUser"`
+
+	// Parts 文件的分片信息，只有通过 ListBucketWithOpts 指定了 NeedParts 并且服务端支持时才会返回
+	Parts []int64 `json:"parts,omitempty"`
 }
 
 // 接口可能返回空的记录
@@ -949,37 +1880,64 @@ func (l *ListItem) String() string {
 	return str
 }
 
-func callChan(r *client.Client, ctx context.Context, method, reqUrl string, headers http.Header) (chan listFilesRet2, error) {
+// jsonNumberRet 使用 json.Decoder.UseNumber 解析响应，避免 interface{} 中的数字被解析为 float64 而丢失精度
+type jsonNumberRet struct {
+	v interface{}
+}
+
+func (r *jsonNumberRet) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(r.v)
+}
+
+// jsonRet 根据 Config.UseJSONNumber 决定解析响应时是否使用 json.Number
+func (m *BucketManager) jsonRet(ret interface{}) interface{} {
+	if ret == nil || !m.Cfg.UseJSONNumber {
+		return ret
+	}
+	return &jsonNumberRet{v: ret}
+}
+
+func callChan(r *client.Client, ctx context.Context, method, reqUrl string, headers http.Header, useNumber bool) (chan listFilesRet2, error) {
 
 	resp, err := r.DoRequestWith(ctx, method, reqUrl, headers, nil, 0)
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode/100 != 2 {
-		return nil, client.ResponseError(resp)
-	}
-	return callRetChan(ctx, resp)
+	return callRetChan(ctx, resp, useNumber)
 }
 
-func callRetChan(ctx context.Context, resp *http.Response) (retCh chan listFilesRet2, err error) {
-
-	retCh = make(chan listFilesRet2)
+// callRetChan 在后台 goroutine 中逐条解析流式列举的响应并发送到 retCh，响应读取完毕、解析失败或者 ctx 取消时关闭 retCh 和响应体，
+// 解析失败时会先发送一条设置了 Err 的记录，以便调用方区分列举完成和列举中断。
+// 调用方不再读取 retCh 时必须取消 ctx，否则后台 goroutine 会一直阻塞在发送上；
+// 请求使用同一个 ctx 发送，因此阻塞在读取响应上的 goroutine 也会在 ctx 取消后退出
+func callRetChan(ctx context.Context, resp *http.Response, useNumber bool) (retCh chan listFilesRet2, err error) {
 	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
 		return nil, client.ResponseError(resp)
 	}
 
+	retCh = make(chan listFilesRet2)
 	go func() {
 		defer resp.Body.Close()
 		defer close(retCh)
 
 		dec := json.NewDecoder(resp.Body)
-		var ret listFilesRet2
+		if useNumber {
+			dec.UseNumber()
+		}
 
 		for {
-			err = dec.Decode(&ret)
-			if err != nil {
-				if err != io.EOF {
-					fmt.Fprintf(os.Stderr, "decode error: %v\n", err)
+			// 每条记录都需要使用新的变量解析，避免上一条记录的字段残留
+			var ret listFilesRet2
+			if dErr := dec.Decode(&ret); dErr != nil {
+				// ctx 取消导致的读取失败不是解析错误
+				if dErr != io.EOF && ctx.Err() == nil {
+					select {
+					case <-ctx.Done():
+					case retCh <- listFilesRet2{Err: dErr}:
+					}
 				}
 				return
 			}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// bucketInfoCache 缓存 GetBucketInfo 的结果，每个空间的结果在 ttl 之后过期，可以被多个 goroutine 同时使用
+type bucketInfoCache struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	items map[string]bucketInfoCacheItem
+}
+
+type bucketInfoCacheItem struct {
+	info      BucketInfo
+	expiresAt time.Time
+}
+
+func newBucketInfoCache(ttl time.Duration) *bucketInfoCache {
+	return &bucketInfoCache{ttl: ttl, items: make(map[string]bucketInfoCacheItem)}
+}
+
+// get 返回空间没有过期的缓存结果
+func (c *bucketInfoCache) get(bucket string, now time.Time) (info BucketInfo, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.items[bucket]
+	if !ok {
+		return
+	}
+	if !now.Before(item.expiresAt) {
+		delete(c.items, bucket)
+		return info, false
+	}
+	return item.info, true
+}
+
+func (c *bucketInfoCache) set(bucket string, info BucketInfo, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[bucket] = bucketInfoCacheItem{info: info, expiresAt: now.Add(c.ttl)}
+}
+
+func (c *bucketInfoCache) invalidate(bucket string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.items, bucket)
+}
+
+// cachedBucketInfo 与 GetBucketInfo 相同，Config.BucketInfoCacheTTL 大于 0 时优先使用缓存的结果，失败的结果不会被缓存
+func (m *BucketManager) cachedBucketInfo(bucket string) (info BucketInfo, err error) {
+	if m.bucketInfos == nil {
+		return m.GetBucketInfo(bucket)
+	}
+	if info, ok := m.bucketInfos.get(bucket, time.Now()); ok {
+		return info, nil
+	}
+	if info, err = m.GetBucketInfo(bucket); err != nil {
+		return
+	}
+	m.bucketInfos.set(bucket, info, time.Now())
+	return
+}
+
+// InvalidateBucketInfo 删除空间信息的缓存，下次使用时重新查询。通过当前 BucketManager 修改空间配置时会自动调用，
+// 只有通过其他方式（如控制台或者其他进程）修改了空间配置时才需要手动调用，没有开启缓存时不做任何操作
+func (m *BucketManager) InvalidateBucketInfo(bucket string) {
+	if m.bucketInfos != nil {
+		m.bucketInfos.invalidate(bucket)
+	}
+}
+
+// bucketInfoChanged 修改空间配置的请求成功后删除空间信息的缓存，返回 err
+func (m *BucketManager) bucketInfoChanged(bucket string, err error) error {
+	if err == nil {
+		m.InvalidateBucketInfo(bucket)
+	}
+	return err
+}
@@ -0,0 +1,76 @@
+// +build unit
+
+package storage
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBucketInfoCache(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		infos   int
+		private = 0
+	)
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/stat/"):
+			w.Write([]byte(`{"hash":"h"}`))
+		case r.URL.Path == "/v2/bucketInfo":
+			infos++
+			fmt.Fprintf(w, `{"private":%d}`, private)
+		case r.URL.Path == "/private":
+			fmt.Sscanf(r.URL.Query().Get("private"), "%d", &private)
+		default:
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+	})
+	defer srv.Close()
+	m.bucketInfos = newBucketInfoCache(time.Hour)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if public, err := m.IsPublic("bucket", "key"); err != nil || !public {
+				t.Errorf("public = %v, err = %v", public, err)
+			}
+		}()
+	}
+	wg.Wait()
+	calls := infos
+	if _, err := m.URLBuilder("bucket"); err != nil || infos != calls {
+		t.Fatalf("bucket info should be cached: calls = %d, err = %v", infos, err)
+	}
+
+	if err := m.MakeBucketPrivate("bucket"); err != nil {
+		t.Fatal(err)
+	}
+	if public, err := m.IsPublic("bucket", "key"); err != nil || public || infos != calls+1 {
+		t.Fatalf("public = %v, err = %v, calls = %d", public, err, infos)
+	}
+
+	m.InvalidateBucketInfo("bucket")
+	if _, err := m.IsPublic("bucket", "key"); err != nil || infos != calls+2 {
+		t.Fatalf("calls = %d, err = %v", infos, err)
+	}
+
+	now := time.Now()
+	c := newBucketInfoCache(time.Minute)
+	c.set("bucket", BucketInfo{Private: 1}, now)
+	if info, ok := c.get("bucket", now.Add(time.Second)); !ok || info.Private != 1 {
+		t.Fatalf("info = %+v, ok = %v", info, ok)
+	}
+	if _, ok := c.get("bucket", now.Add(time.Minute)); ok {
+		t.Fatal("cached bucket info should expire")
+	}
+}
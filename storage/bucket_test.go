@@ -248,6 +248,32 @@ func TestCopyMoveDelete(t *testing.T) {
 	}
 }
 
+func TestCopyWithEndUser(t *testing.T) {
+	destKey := "qiniu_enduser.png"
+	endUser := "tenant-a"
+	_, err := bucketManager.CopyWithOpts(testBucket, testKey, testBucket, destKey, &CopyOpts{Force: true, EndUser: endUser})
+	if err != nil {
+		t.Fatalf("CopyWithOpts() error, %s", err)
+	}
+	defer bucketManager.Delete(testBucket, destKey)
+
+	ret, err := bucketManager.CopyWithOpts(testBucket, testKey, testBucket, destKey, &CopyOpts{Force: true, EndUser: endUser, DetectOverwrite: true})
+	if err != nil {
+		t.Fatalf("CopyWithOpts() error, %s", err)
+	}
+	if !ret.Overwritten {
+		t.Fatal("CopyWithOpts() should report the destination was overwritten")
+	}
+
+	info, err := bucketManager.Stat(testBucket, destKey)
+	if err != nil {
+		t.Fatalf("Stat() error, %s", err)
+	}
+	if info.EndUser != endUser {
+		t.Fatalf("CopyWithOpts() endUser error, want: %s, got: %s", endUser, info.EndUser)
+	}
+}
+
 func TestFetch(t *testing.T) {
 	ret, err := bucketManager.Fetch(testFetchUrl, testBucket, "qiniu-fetch.png")
 	if err != nil {
@@ -0,0 +1,1028 @@
+// +build unit
+
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qiniu/go-sdk/v7/auth"
+	"github.com/qiniu/go-sdk/v7/client"
+)
+
+// newMockBucketManager 构建一个所有请求都发送到 httptest.Server 的 BucketManager
+func newMockBucketManager(handler http.HandlerFunc) (*BucketManager, *httptest.Server) {
+	srv := httptest.NewServer(handler)
+	cfg := Config{
+		RsHost:        srv.URL,
+		RsfHost:       srv.URL,
+		ApiHost:       srv.URL,
+		IoHost:        srv.URL,
+		UcHost:        srv.URL,
+		CentralRsHost: strings.TrimPrefix(srv.URL, "http://"),
+	}
+	clt := client.Client{Client: srv.Client()}
+	mac := auth.New("ak", "sk")
+	return NewBucketManagerEx(mac, &cfg, &clt), srv
+}
+
+func TestBucketManagerDo(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/custom" || r.URL.Query().Get("bucket") != "bucket" {
+			t.Errorf("unexpected url: %s", r.URL)
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), auth.AuthorizationPrefixQiniu) {
+			t.Errorf("unexpected authorization: %s", r.Header.Get("Authorization"))
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != `{"name":"value"}` {
+			t.Errorf("unexpected body: %s", body)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"123"}`))
+	})
+	defer srv.Close()
+
+	var ret struct {
+		ID string `json:"id"`
+	}
+	body := map[string]string{"name": "value"}
+	err := m.Do(context.Background(), "POST", HostCategoryRs, "bucket", "/v2/custom?bucket=bucket", body, &ret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.ID != "123" {
+		t.Fatalf("unexpected ret: %v", ret)
+	}
+
+	if _, err = m.ReqHostByCategory(HostCategory("unknown"), ""); err == nil {
+		t.Fatal("expect error for unknown host category")
+	}
+}
+
+func TestListBucketSorted(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		for _, line := range []string{
+			`{"marker":"m1","item":{"key":"b"}}`,
+			`{"marker":"m2","dir":"a/"}`,
+			`{"marker":"m3","item":{"key":"c"}}`,
+			`{"marker":"m4","item":{"key":"a0"}}`,
+		} {
+			w.Write([]byte(line + "\n"))
+		}
+	})
+	defer srv.Close()
+
+	cases := []struct {
+		pageSize int
+		want     []string
+	}{
+		{pageSize: 2, want: []string{"a/", "b", "a0", "c"}},
+		{pageSize: 0, want: []string{"a/", "a0", "b", "c"}},
+	}
+	for _, c := range cases {
+		retCh, err := m.ListBucketSorted(context.Background(), "bucket", "", "/", "", c.pageSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for ret := range retCh {
+			got = append(got, ret.name())
+		}
+		if strings.Join(got, ",") != strings.Join(c.want, ",") {
+			t.Errorf("pageSize = %d, want = %v, got = %v", c.pageSize, c.want, got)
+		}
+	}
+}
+
+type closeIdleTransport struct {
+	http.RoundTripper
+	closed int
+}
+
+func (t *closeIdleTransport) CloseIdleConnections() {
+	t.closed++
+}
+
+func TestBucketManagerClose(t *testing.T) {
+	transport := &closeIdleTransport{RoundTripper: http.DefaultTransport}
+	m := NewBucketManagerEx(auth.New("ak", "sk"), nil, &client.Client{Client: &http.Client{Transport: transport}})
+	m.Close()
+	m.Close()
+	if transport.closed != 2 {
+		t.Fatalf("CloseIdleConnections called %d times, want 2", transport.closed)
+	}
+
+	// 共享的默认客户端不应该被关闭，即使替换了 Transport
+	NewBucketManager(auth.New("ak", "sk"), nil).Close()
+	defaultClient := client.DefaultClient
+	defer func() { client.DefaultClient = defaultClient }()
+	shared := &closeIdleTransport{RoundTripper: http.DefaultTransport}
+	client.DefaultClient = client.Client{Client: &http.Client{Transport: shared}}
+	NewBucketManager(auth.New("ak", "sk"), nil).Close()
+	if shared.closed != 0 {
+		t.Fatalf("shared default client closed %d times", shared.closed)
+	}
+}
+
+func TestCopyWithOptsDetectOverwrite(t *testing.T) {
+	var paths []string
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasPrefix(r.URL.Path, "/stat/") {
+			w.WriteHeader(612)
+			w.Write([]byte(`{"error":"no such file or directory"}`))
+		}
+	})
+	defer srv.Close()
+
+	ret, err := m.CopyWithOpts("src", "a", "dest", "b", &CopyOpts{Force: true, DetectOverwrite: true, EndUser: "tenant"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.Overwritten {
+		t.Fatal("destination does not exist, should not be overwritten")
+	}
+	want := []string{URIStat("dest", "b"), URICopyWithOpts("src", "a", "dest", "b", &CopyOpts{Force: true, EndUser: "tenant"})}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Fatalf("want = %v, got = %v", want, paths)
+	}
+}
+
+func TestCopyAndSign(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != URICopy("src", "a", "dest", "b", false) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(614)
+			w.Write([]byte(`{"error":"file exists"}`))
+		}
+	})
+	defer srv.Close()
+
+	mac := auth.New("ak", "sk")
+	privateURL, err := m.CopyAndSign(mac, "src", "a", "dest", "b", "https://cdn.example.com", false, 1609459200)
+	if err != nil || privateURL != MakePrivateURLv2(mac, "https://cdn.example.com", "b", 1609459200) {
+		t.Fatalf("privateURL = %s, err = %v", privateURL, err)
+	}
+	privateURL, err = m.CopyAndSign(mac, "src", "a", "dest", "c", "https://cdn.example.com", false, 1609459200)
+	if err == nil || privateURL != "" {
+		t.Fatalf("should not sign when copy fails: privateURL = %s, err = %v", privateURL, err)
+	}
+}
+
+func TestMoveIfSourceExists(t *testing.T) {
+	var paths []string
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case URIStat("src", "a"):
+			w.Write([]byte(`{"hash":"h"}`))
+		case URIMove("src", "a", "dest", "b", true):
+		default:
+			w.WriteHeader(612)
+			w.Write([]byte(`{"error":"no such file or directory"}`))
+		}
+	})
+	defer srv.Close()
+
+	if err := m.MoveIfSourceExists(context.Background(), "src", "a", "dest", "b", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.MoveIfSourceExists(context.Background(), "src", "missing", "dest", "b", true); err != ErrNoSuchEntry {
+		t.Fatalf("want ErrNoSuchEntry, got %v", err)
+	}
+	want := []string{URIStat("src", "a"), URIMove("src", "a", "dest", "b", true), URIStat("src", "missing")}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Fatalf("want = %v, got = %v", want, paths)
+	}
+}
+
+func TestDeleteIfHash(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != uriDeleteIfHash("bucket", "key", "expected") {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(608)
+			w.Write([]byte(`{"error":"file modified"}`))
+		}
+	})
+	defer srv.Close()
+
+	if err := m.DeleteIfHash(context.Background(), "bucket", "key", "expected"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DeleteIfHash(context.Background(), "bucket", "key", "other"); err != ErrConditionNotMet {
+		t.Fatalf("want ErrConditionNotMet, got %v", err)
+	}
+}
+
+func TestUseJSONNumber(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"putTime":16094592000001234}`))
+	})
+	defer srv.Close()
+
+	for _, useNumber := range []bool{false, true} {
+		m.Cfg.UseJSONNumber = useNumber
+		var ret map[string]interface{}
+		if err := m.Do(context.Background(), "GET", HostCategoryRs, "", "/stat", nil, &ret); err != nil {
+			t.Fatal(err)
+		}
+		n, ok := ret["putTime"].(json.Number)
+		if ok != useNumber {
+			t.Fatalf("UseJSONNumber = %v, got %T", useNumber, ret["putTime"])
+		}
+		if ok && n.String() != "16094592000001234" {
+			t.Fatalf("unexpected putTime: %s", n)
+		}
+	}
+}
+
+func TestKeyNotUTF8(t *testing.T) {
+	requests := 0
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"key must be utf8 encoding"}`))
+	})
+	defer srv.Close()
+
+	badKey := "bad\xff"
+	err := m.Move("src", "a", "dest", badKey, false)
+	entryErr, ok := err.(*EntryError)
+	if !ok || entryErr.Err != ErrKeyNotUTF8 || entryErr.Bucket != "dest" || entryErr.Key != badKey {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+	if requests != 1 {
+		t.Fatalf("requests = %d, want 1", requests)
+	}
+
+	m.Cfg.AutoValidateKeys = true
+	_, err = m.Stat("bucket", badKey)
+	if entryErr, ok = err.(*EntryError); !ok || entryErr.Err != ErrKeyNotUTF8 || entryErr.Key != badKey {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+	if requests != 1 {
+		t.Fatalf("request should not be sent when AutoValidateKeys is set, requests = %d", requests)
+	}
+}
+
+func TestEncodedEntryRoundTrip(t *testing.T) {
+	keys := []string{
+		"",
+		"a",
+		"ab",
+		"a+b/c=d",
+		"with space",
+		"a:b:c",
+		"???>>>",
+		"中文/文件名.txt",
+		"emoji😀",
+	}
+	for _, key := range keys {
+		for _, encoded := range []string{EncodedEntry("bucket", key), EncodedEntryNoPad("bucket", key)} {
+			if strings.ContainsAny(encoded, "+/") {
+				t.Errorf("encoded entry should be url safe: %s", encoded)
+			}
+			bucket, decodedKey, err := DecodeEntry(encoded)
+			if err != nil {
+				t.Fatalf("key = %q, encoded = %s, err = %v", key, encoded, err)
+			}
+			if bucket != "bucket" || decodedKey != key {
+				t.Errorf("key = %q, got bucket = %q, key = %q", key, bucket, decodedKey)
+			}
+		}
+		if strings.Contains(EncodedEntryNoPad("bucket", key), "=") {
+			t.Errorf("EncodedEntryNoPad should not be padded: %s", EncodedEntryNoPad("bucket", key))
+		}
+	}
+
+	bucket, key, err := DecodeEntry(EncodedEntryWithoutKey("bucket"))
+	if err != nil || bucket != "bucket" || key != "" {
+		t.Fatalf("bucket = %q, key = %q, err = %v", bucket, key, err)
+	}
+	if _, _, err = DecodeEntry("!invalid"); err == nil {
+		t.Fatal("expect error for invalid entry")
+	}
+}
+
+func TestConfigUcHost(t *testing.T) {
+	var path string
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+	})
+	defer srv.Close()
+
+	if err := m.CreateBucket("bucket", RIDHuadong); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/mkbucketv3/bucket/region/z0" {
+		t.Fatalf("unexpected path: %s", path)
+	}
+
+	m.Cfg.CentralUcHost = strings.TrimPrefix(srv.URL, "http://")
+	m.Cfg.UcHost = "unreachable.example.com"
+	if err := m.DropBucket("bucket"); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/drop/bucket" {
+		t.Fatalf("unexpected path: %s", path)
+	}
+
+	cfg := Config{UcHost: "uc.example.com", UseHTTPS: true}
+	if host := cfg.UcReqHost(); host != "https://uc.example.com" {
+		t.Fatalf("unexpected uc host: %s", host)
+	}
+	if host := (&Config{}).UcReqHost(); host != getUcHost(false) {
+		t.Fatalf("unexpected default uc host: %s", host)
+	}
+}
+
+func TestVerifyFetchResult(t *testing.T) {
+	md5 := "d41d8cd98f00b204e9800998ecf8427e"
+	cases := []struct {
+		ret  FetchRet
+		want error
+	}{
+		{ret: FetchRet{Md5: md5}, want: nil},
+		{ret: FetchRet{Md5: strings.ToUpper(md5)}, want: nil},
+		{ret: FetchRet{Md5: "0cc175b9c0f1b6a831c399e269772661"}, want: ErrMd5Mismatch},
+		{ret: FetchRet{}, want: ErrMd5Unavailable},
+	}
+	for _, c := range cases {
+		if err := VerifyFetchResult(md5, c.ret); err != c.want {
+			t.Errorf("md5 = %s, want = %v, got = %v", c.ret.Md5, c.want, err)
+		}
+	}
+}
+
+func TestListFlat(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("delimiter") != "" {
+			t.Errorf("ListFlat should not use delimiter: %s", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		for _, line := range []string{
+			`{"marker":"m1","item":{"key":"a"}}`,
+			`{"marker":"m2","dir":"b/"}`,
+			`{"marker":"m3","item":{"key":"b/c"}}`,
+		} {
+			w.Write([]byte(line + "\n"))
+		}
+	})
+	defer srv.Close()
+
+	retCh, err := m.ListFlat(context.Background(), "bucket", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for entry := range retCh {
+		if entry.Err != nil {
+			t.Fatal(entry.Err)
+		}
+		keys = append(keys, entry.Item.Key)
+	}
+	if strings.Join(keys, ",") != "a,b/c" {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+}
+
+func TestListStreamDecodeError(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		// 第二条记录不完整，模拟连接中断
+		w.Write([]byte(`{"marker":"m1","item":{"key":"a"}}` + "\n" + `{"marker":"m2","item":{"ke`))
+	})
+	defer srv.Close()
+
+	retCh, err := m.ListBucketContext(context.Background(), "bucket", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rets []listFilesRet2
+	for ret := range retCh {
+		rets = append(rets, ret)
+	}
+	if len(rets) != 2 || rets[0].Item.Key != "a" || rets[1].Err == nil {
+		t.Fatalf("unexpected rets: %+v", rets)
+	}
+
+	flatCh, err := m.ListFlat(context.Background(), "bucket", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []ListEntry
+	for entry := range flatCh {
+		entries = append(entries, entry)
+	}
+	if len(entries) != 2 || entries[0].Item.Key != "a" || entries[1].Err == nil || entries[1].Item != nil {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+
+	entryCh, err := m.ListBucketEntries(context.Background(), "bucket", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries = entries[:0]
+	for entry := range entryCh {
+		entries = append(entries, entry)
+	}
+	if len(entries) != 2 || entries[1].Err == nil {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+
+	sortedCh, err := m.ListBucketSorted(context.Background(), "bucket", "", "", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rets = rets[:0]
+	for ret := range sortedCh {
+		rets = append(rets, ret)
+	}
+	if len(rets) != 2 || rets[0].Item.Key != "a" || rets[1].Err == nil {
+		t.Fatalf("unexpected sorted rets: %+v", rets)
+	}
+}
+
+func TestNewBucketManagerTimeouts(t *testing.T) {
+	m := NewBucketManager(auth.New("ak", "sk"), &Config{})
+	if m.Client != &client.DefaultClient {
+		t.Fatal("should use client.DefaultClient without timeouts")
+	}
+
+	m = NewBucketManager(auth.New("ak", "sk"), &Config{TLSHandshakeTimeout: 3 * time.Second})
+	transport, ok := m.Client.Client.Transport.(*http.Transport)
+	if !ok || transport.TLSHandshakeTimeout != 3*time.Second || transport.DialContext == nil {
+		t.Fatalf("unexpected transport: %#v", m.Client.Client.Transport)
+	}
+	if m.Client.Client.Timeout != 0 {
+		t.Fatal("request timeout should not be set")
+	}
+	m.Close()
+}
+
+func TestCheckBucketAccess(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("limit") != "1" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("bucket") {
+		case "missing":
+			w.WriteHeader(631)
+			w.Write([]byte(`{"error":"no such bucket"}`))
+		case "forbidden":
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"bad token"}`))
+		default:
+			w.Write([]byte(`{"items":[]}`))
+		}
+	})
+	defer srv.Close()
+
+	cases := map[string]error{
+		"bucket":    nil,
+		"missing":   ErrNoSuchBucket,
+		"forbidden": ErrAccessDenied,
+	}
+	for bucket, want := range cases {
+		if err := m.CheckBucketAccess(context.Background(), bucket); err != want {
+			t.Errorf("bucket = %s, want = %v, got = %v", bucket, want, err)
+		}
+	}
+}
+
+func TestNoSuchBucket(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Reqid", "req")
+		w.WriteHeader(631)
+		w.Write([]byte(`{"error":"no such bucket"}`))
+	})
+	defer srv.Close()
+
+	// 已有的方法仍然返回 *ErrorInfo，保留错误码、Reqid 以及服务端返回的错误信息
+	for name, call := range map[string]func() error{
+		"Stat":      func() error { _, err := m.Stat("missing", "key"); return err },
+		"Move":      func() error { return m.Move("missing", "a", "missing", "b", false) },
+		"Batch":     func() error { _, err := m.Batch([]string{URIDelete("missing", "key")}); return err },
+		"ListFiles": func() error { _, _, _, _, err := m.ListFiles("missing", "", "", "", 10); return err },
+		"Exists":    func() error { _, err := m.Exists("missing", "key"); return err },
+	} {
+		err := call()
+		errInfo, ok := err.(*ErrorInfo)
+		if !ok || errInfo.Code != ErrorCodeNoSuchBucket || errInfo.Reqid != "req" || errInfo.Err != "no such bucket" || !IsNoSuchBucket(err) {
+			t.Fatalf("%s: want *ErrorInfo with code 631, got %#v", name, err)
+		}
+	}
+	if !IsNoSuchBucket(ErrNoSuchBucket) || !IsNoSuchBucket(&EntryError{Err: &ErrorInfo{Code: 631}}) || IsNoSuchBucket(&ErrorInfo{Code: 612}) || IsNoSuchBucket(nil) {
+		t.Fatal("unexpected IsNoSuchBucket result")
+	}
+}
+
+func TestErrorCodeMessage(t *testing.T) {
+	for _, c := range []struct {
+		code int
+		want string
+	}{
+		{ErrorCodeConditionNotMet, "condition not met"},
+		{ErrorCodeNoSuchEntry, "no such file or directory"},
+		{ErrorCodeEntryExists, "file exists"},
+		{ErrorCodeNoSuchBucket, "no such bucket"},
+		{ErrorCodeInvalidMarker, "invalid marker"},
+		{ErrorCodeTooManyRequests, "too many requests"},
+		{ErrorCodeCallbackFailed, "callback failed"},
+		{ErrorCodeInvalidContext, "invalid upload context"},
+		{0, ""},
+		{200, ""},
+		{999, ""},
+	} {
+		if got := ErrorCodeMessage(c.code); got != c.want {
+			t.Errorf("ErrorCodeMessage(%d) = %q, want %q", c.code, got, c.want)
+		}
+	}
+}
+
+func TestFetchReader(t *testing.T) {
+	var uploaded string
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/" {
+			file, _, err := r.FormFile("file")
+			if err != nil {
+				t.Error(err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			data, _ := ioutil.ReadAll(file)
+			uploaded = string(data)
+			if token := r.FormValue("token"); !strings.HasPrefix(token, "ak:") {
+				t.Errorf("unexpected token: %s", token)
+			}
+			w.Write([]byte(`{"hash":"h","key":"key"}`))
+			return
+		}
+		if r.URL.Path != URIStat("bucket", "key") {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"hash":"h","fsize":7,"putTime":1}`))
+	})
+	defer srv.Close()
+	m.Cfg.Region = &Region{SrcUpHosts: []string{strings.TrimPrefix(srv.URL, "http://")}}
+
+	info, err := m.FetchReader(context.Background(), "bucket", "key", strings.NewReader("content"), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uploaded != "content" || info.Hash != "h" || info.Fsize != 7 {
+		t.Fatalf("uploaded = %s, info = %+v", uploaded, info)
+	}
+}
+
+func TestAsyncFetchRaw(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/sisyphus/fetch" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if body["bucket"] != "bucket" || body["ignore_same_key"] != true {
+			t.Errorf("unexpected body: %v", body)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"job","wait":3}`))
+	})
+	defer srv.Close()
+
+	body := json.RawMessage(`{"url":"http://example.com/a","bucket":"bucket","ignore_same_key":true}`)
+	ret, err := m.AsyncFetchRaw(context.Background(), "bucket", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.Id != "job" || ret.Wait != 3 {
+		t.Fatalf("unexpected ret: %+v", ret)
+	}
+	if _, err = m.AsyncFetchRaw(context.Background(), "bucket", nil); err == nil {
+		t.Fatal("expect error for nil body")
+	}
+}
+
+func TestWaitAsyncFetch(t *testing.T) {
+	defer func(interval time.Duration) {
+		asyncFetchPollInterval = interval
+	}(asyncFetchPollInterval)
+	asyncFetchPollInterval = time.Millisecond
+
+	var queries int
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/sisyphus/fetch" || r.URL.Query().Get("id") != "job" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+		}
+		queries++
+		w.Header().Set("Content-Type", "application/json")
+		if queries < 3 {
+			w.Write([]byte(`{"id":"job","wait":0}`))
+		} else {
+			w.Write([]byte(`{"id":"job","wait":-1}`))
+		}
+	})
+	defer srv.Close()
+
+	status, err := m.WaitAsyncFetch(context.Background(), "bucket", AsyncFetchRet{Id: "job"})
+	if err != nil || status.Wait != -1 || queries != 3 {
+		t.Fatalf("status = %+v, err = %v, queries = %d", status, err, queries)
+	}
+
+	// 第一次查询前按照 Wait 等待
+	queries = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err = m.WaitAsyncFetch(ctx, "bucket", AsyncFetchRet{Id: "job", Wait: 1}); err != context.DeadlineExceeded || queries != 0 {
+		t.Fatalf("err = %v, queries = %d", err, queries)
+	}
+
+	for wait, want := range map[int]time.Duration{3: 3 * time.Second, 0: 0, -1: 0} {
+		ret := AsyncFetchRet{Wait: wait}
+		if d := ret.ShouldRetryAfter(); d != want {
+			t.Errorf("ShouldRetryAfter() with wait %d = %v, want %v", wait, d, want)
+		}
+	}
+}
+
+func TestDisableZoneDiscovery(t *testing.T) {
+	m := NewBucketManager(auth.New("ak", "sk"), &Config{DisableZoneDiscovery: true})
+	_, err := m.RsReqHost("bucket")
+	if err == nil || !strings.Contains(err.Error(), "Config.RsHost") {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, err = m.Zone("bucket"); err != ErrZoneDiscoveryDisabled {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, err = getUpHost(m.Cfg, "ak", "bucket"); err == nil || !strings.Contains(err.Error(), "Config.Region") {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	m.Cfg.RsHost = "rs.example.com"
+	host, err := m.RsReqHost("bucket")
+	if err != nil || host != "http://rs.example.com" {
+		t.Fatalf("host = %s, err = %v", host, err)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/stat/"+EncodedEntry("bucket", "key") {
+			w.WriteHeader(612)
+			w.Write([]byte(`{"error":"no such file or directory"}`))
+			return
+		}
+		w.Write([]byte(`{"hash":"etag","fsize":1}`))
+	})
+	defer srv.Close()
+
+	if matched, err := m.Matches("bucket", "key", "etag"); err != nil || !matched {
+		t.Fatalf("matched = %v, err = %v", matched, err)
+	}
+	if matched, err := m.Matches("bucket", "key", "other"); err != nil || matched {
+		t.Fatalf("matched = %v, err = %v", matched, err)
+	}
+	if _, err := m.Matches("bucket", "missing", "etag"); err != ErrNoSuchEntry {
+		t.Fatalf("want ErrNoSuchEntry, got %v", err)
+	}
+}
+
+func TestChangeTypeIdempotent(t *testing.T) {
+	var chtypes int
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/stat/"):
+			w.Write([]byte(`{"hash":"h","type":1}`))
+		case strings.HasPrefix(r.URL.Path, "/chtype/"):
+			chtypes++
+			if strings.HasSuffix(r.URL.Path, "/type/1") {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"already in line stat"}`))
+			}
+		}
+	})
+	defer srv.Close()
+
+	if err := m.ChangeTypeIdempotent(context.Background(), "bucket", "key", StorageTypeIA); err != nil || chtypes != 0 {
+		t.Fatalf("chtypes = %d, err = %v", chtypes, err)
+	}
+	if err := m.ChangeTypeIdempotent(context.Background(), "bucket", "key", StorageTypeArchive); err != nil || chtypes != 1 {
+		t.Fatalf("chtypes = %d, err = %v", chtypes, err)
+	}
+	if err := m.ChangeTypeStrict(context.Background(), "bucket", "key", StorageTypeIA); err != ErrSameStorageType || chtypes != 1 {
+		t.Fatalf("want ErrSameStorageType, got %v, chtypes = %d", err, chtypes)
+	}
+	err := m.ChangeType("bucket", "key", 1)
+	if errInfo, ok := err.(*ErrorInfo); !ok || errInfo.Code != http.StatusBadRequest || chtypes != 2 {
+		t.Fatalf("ChangeType should return the server error, got %#v, chtypes = %d", err, chtypes)
+	}
+}
+
+func TestMakePrivateURLsWithDeadlines(t *testing.T) {
+	mac := auth.New("ak", "sk")
+	urls := MakePrivateURLsWithDeadlines(mac, "http://example.com/", map[string]int64{"a b": 100, "c": 200})
+	if len(urls) != 2 {
+		t.Fatalf("unexpected urls: %v", urls)
+	}
+	if want := MakePrivateURLv2(mac, "http://example.com", "a b", 100); urls["a b"] != want {
+		t.Fatalf("want %s, got %s", want, urls["a b"])
+	}
+	if want := MakePrivateURLv2(mac, "http://example.com", "c", 200); urls["c"] != want {
+		t.Fatalf("want %s, got %s", want, urls["c"])
+	}
+}
+
+func TestMakeURLs(t *testing.T) {
+	mac := auth.New("ak", "sk")
+	urls := MakeURLs(mac, "http://example.com/", []URLRequest{{Key: "a b", Private: true}, {Key: "c d"}}, 100)
+	if len(urls) != 2 {
+		t.Fatalf("unexpected urls: %v", urls)
+	}
+	if want := MakePrivateURLv2(mac, "http://example.com", "a b", 100); urls[0] != want {
+		t.Fatalf("want %s, got %s", want, urls[0])
+	}
+	if want := MakePublicURLv2("http://example.com", "c d"); urls[1] != want {
+		t.Fatalf("want %s, got %s", want, urls[1])
+	}
+}
+
+func TestListBucketEntries(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		for _, line := range []string{
+			`{"marker":"m1","item":{"key":"a"}}`,
+			`{"marker":"m2","dir":"b/"}`,
+			`{"marker":"m3","item":{"key":"c"}}`,
+		} {
+			w.Write([]byte(line + "\n"))
+		}
+	})
+	defer srv.Close()
+
+	retCh, err := m.ListBucketEntries(context.Background(), "bucket", "", "/", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for entry := range retCh {
+		if entry.IsDir() {
+			names = append(names, "dir:"+entry.Dir)
+		} else {
+			names = append(names, "file:"+entry.Item.Key)
+		}
+	}
+	if strings.Join(names, ",") != "file:a,dir:b/,file:c" {
+		t.Fatalf("unexpected entries: %v", names)
+	}
+}
+
+func TestFetchWithOpts(t *testing.T) {
+	var paths []string
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)[0])
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == URIChangeType("bucket", "fail", int(StorageTypeArchive)) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"bad request"}`))
+			return
+		}
+		w.Write([]byte(`{"hash":"h","key":"key","fsize":1}`))
+	})
+	defer srv.Close()
+
+	if _, err := m.FetchWithOpts("http://example.com/a", "bucket", "key", &FetchOpts{FileType: 5}); err == nil {
+		t.Fatal("expect error for invalid file type")
+	}
+	ret, err := m.FetchWithOpts("http://example.com/a", "bucket", "key", nil)
+	if err != nil || ret.Hash != "h" || strings.Join(paths, ",") != "fetch" {
+		t.Fatalf("ret = %+v, paths = %v, err = %v", ret, paths, err)
+	}
+	paths = nil
+	if _, err = m.FetchWithOpts("http://example.com/a", "bucket", "key", &FetchOpts{FileType: int(StorageTypeArchive)}); err != nil || strings.Join(paths, ",") != "fetch,stat,chtype" {
+		t.Fatalf("paths = %v, err = %v", paths, err)
+	}
+	// 修改存储类型失败时文件已经抓取成功
+	if ret, err = m.FetchWithOpts("http://example.com/a", "bucket", "fail", &FetchOpts{FileType: int(StorageTypeArchive)}); err == nil || ret.Hash != "h" {
+		t.Fatalf("ret = %+v, err = %v", ret, err)
+	}
+}
+
+func TestRestoreArWithExpiry(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != URIRestoreAr("bucket", "key", 3) {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+	})
+	defer srv.Close()
+
+	before := time.Now()
+	expiresAt, err := m.RestoreArWithExpiry("bucket", "key", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expiresAt.Before(before.Add(72*time.Hour)) || expiresAt.After(time.Now().Add(72*time.Hour)) {
+		t.Fatalf("unexpected expiresAt: %s", expiresAt)
+	}
+}
+
+func TestArchiveStatus(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("needparts") != "true" {
+			t.Errorf("needparts not set: %s", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"hash":"h","type":2,"restoreStatus":1,"parts":[4194304,100]}`))
+	})
+	defer srv.Close()
+
+	restoring, restored, parts, err := m.ArchiveStatus(context.Background(), "bucket", "key")
+	if err != nil || !restoring || restored || len(parts) != 2 || parts[1] != 100 {
+		t.Fatalf("restoring = %v, restored = %v, parts = %v, err = %v", restoring, restored, parts, err)
+	}
+}
+
+func TestFileInfoRestoreState(t *testing.T) {
+	for _, c := range []struct {
+		info                        FileInfo
+		frozen, restoring, restored bool
+	}{
+		{FileInfo{Type: 0}, false, false, false},
+		{FileInfo{Type: 1, RestoreStatus: 2}, false, false, false},
+		{FileInfo{Type: 2}, true, false, false},
+		{FileInfo{Type: 2, RestoreStatus: 1}, false, true, false},
+		{FileInfo{Type: 3, RestoreStatus: 2}, false, false, true},
+		{FileInfo{Type: 3}, true, false, false},
+	} {
+		if c.info.IsFrozen() != c.frozen || c.info.IsRestoring() != c.restoring || c.info.IsRestored() != c.restored {
+			t.Errorf("type = %d, restoreStatus = %d: frozen = %v, restoring = %v, restored = %v", c.info.Type, c.info.RestoreStatus,
+				c.info.IsFrozen(), c.info.IsRestoring(), c.info.IsRestored())
+		}
+	}
+}
+
+func TestDefaultBucket(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != URIStat("default", "key") {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"hash":"h"}`))
+	})
+	defer srv.Close()
+
+	if _, err := m.StatKey("key"); err != ErrDefaultBucketNotSet {
+		t.Fatalf("want ErrDefaultBucketNotSet, got %v", err)
+	}
+	m.Cfg.DefaultBucket = "default"
+	if info, err := m.StatKey("key"); err != nil || info.Hash != "h" {
+		t.Fatalf("info = %+v, err = %v", info, err)
+	}
+}
+
+func TestMakePrivateURLWithExpiry(t *testing.T) {
+	mac := auth.New("ak", "sk")
+	before := time.Now()
+	privateURL, expiresAt, err := MakePrivateURLWithExpiry(mac, "https://cdn.example.com", "a b", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(privateURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := u.Query().Get("e"); e != strconv.FormatInt(expiresAt.Unix(), 10) {
+		t.Fatalf("e = %s, expiresAt = %d", e, expiresAt.Unix())
+	}
+	if expiresAt.Before(before.Add(time.Hour).Truncate(time.Second)) || expiresAt.After(time.Now().Add(time.Hour)) {
+		t.Fatalf("unexpected expiresAt: %v", expiresAt)
+	}
+	if privateURL != MakePrivateURLv2(mac, "https://cdn.example.com", "a b", expiresAt.Unix()) {
+		t.Fatalf("unexpected url: %s", privateURL)
+	}
+	if _, _, err = MakePrivateURLWithExpiry(mac, "https://cdn.example.com", "key", 0); err == nil {
+		t.Fatal("expect error for zero ttl")
+	}
+}
+
+func TestMakePrivateURLWithPathPrefix(t *testing.T) {
+	mac := auth.New("ak", "sk")
+	urlToSign := "https://cdn.example.com/assets/img/a%20b.jpg?e=100"
+	want := urlToSign + "&token=" + mac.Sign([]byte(urlToSign))
+	for _, prefix := range []string{"assets", "/assets/"} {
+		if privateURL := MakePrivateURLWithPathPrefix(mac, "https://cdn.example.com/", prefix, "img/a b.jpg", 100); privateURL != want {
+			t.Fatalf("prefix %q: want %s, got %s", prefix, want, privateURL)
+		}
+	}
+	if privateURL := MakePrivateURLWithPathPrefix(mac, "https://cdn.example.com", "", "key", 100); privateURL != MakePrivateURLv2(mac, "https://cdn.example.com", "key", 100) {
+		t.Fatalf("unexpected url: %s", privateURL)
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	at := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	if deadline := DeadlineAt(at); deadline != 1609459200 {
+		t.Fatalf("deadline = %d", deadline)
+	}
+	before := time.Now().Add(time.Hour).Unix()
+	if deadline := Deadline(time.Hour); deadline < before || deadline > time.Now().Add(time.Hour).Unix() {
+		t.Fatalf("deadline = %d", deadline)
+	}
+}
+
+func TestDisableCompression(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "identity" {
+			t.Errorf("unexpected Accept-Encoding: %q", r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"hash":"h"}`))
+	}))
+	defer srv.Close()
+
+	m := NewBucketManager(auth.New("ak", "sk"), &Config{RsHost: srv.URL, DisableCompression: true})
+	if m.Client == &client.DefaultClient {
+		t.Fatal("should not use client.DefaultClient when compression is disabled")
+	}
+	if info, err := m.Stat("bucket", "key"); err != nil || info.Hash != "h" {
+		t.Fatalf("info = %+v, err = %v", info, err)
+	}
+	m.Close()
+}
+
+func TestCanonicalSignString(t *testing.T) {
+	mac := auth.New("ak", "sk")
+	if s := CanonicalSignString("http://example.com", "a b", "", 100); s != "http://example.com/a%20b?e=100" {
+		t.Fatalf("unexpected sign string: %s", s)
+	}
+	s := CanonicalSignString("http://example.com", "key", "x=1", 100)
+	if s != "http://example.com/key?x=1&e=100" {
+		t.Fatalf("unexpected sign string: %s", s)
+	}
+	want := s + "&token=" + mac.Sign([]byte(s))
+	if privateURL := MakePrivateURLv2WithQuery(mac, "http://example.com", "key", url.Values{"x": {"1"}}, 100); privateURL != want {
+		t.Fatalf("want %s, got %s", want, privateURL)
+	}
+}
+
+func TestURITag(t *testing.T) {
+	uri := URITag("bucket", "key", map[string]string{"team": "a/b", "env": "prod"})
+	want := "/chgm/" + EncodedEntry("bucket", "key") +
+		"/x-qn-meta-env/" + base64.URLEncoding.EncodeToString([]byte("prod")) +
+		"/x-qn-meta-team/" + base64.URLEncoding.EncodeToString([]byte("a/b"))
+	if uri != want {
+		t.Fatalf("want %s, got %s", want, uri)
+	}
+	if err := ValidateTags(map[string]string{"team-1_a": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tags := range []map[string]string{nil, {"": "x"}, {"a/b": "x"}, {"标签": "x"}} {
+		if err := ValidateTags(tags); err == nil {
+			t.Fatalf("expect error for %v", tags)
+		}
+	}
+}
+
+func TestAsyncFetchCallbackBodyType(t *testing.T) {
+	var bodyType interface{}
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		bodyType = body["callbackbodytype"]
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"job"}`))
+	})
+	defer srv.Close()
+
+	param := AsyncFetchParam{Url: "http://example.com/a", Bucket: "bucket", CallbackBodyType: " Application/JSON "}
+	if _, err := m.AsyncFetch(param); err != nil || bodyType != CallbackBodyTypeJSON {
+		t.Fatalf("bodyType = %v, err = %v", bodyType, err)
+	}
+	param.CallbackBodyType = "text/plain"
+	if _, err := m.AsyncFetch(param); err == nil {
+		t.Fatal("expect error for invalid callback body type")
+	}
+}
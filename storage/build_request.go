@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/qiniu/go-sdk/v7/auth"
+	"github.com/qiniu/go-sdk/v7/client"
+)
+
+// buildEntryRequest 构造与 entryCall 发送的请求完全相同的 *http.Request（已经解析域名并添加签名），但不发送
+func (m *BucketManager) buildEntryRequest(ctx context.Context, hostCategory HostCategory, path string, entries ...BucketKey) (req *http.Request, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	reqURL, err := m.entryURL(hostCategory, path, entries...)
+	if err != nil {
+		return
+	}
+	ctx = auth.WithCredentialsType(ctx, m.Mac, auth.TokenQiniu)
+	return client.NewRequest(ctx, "POST", reqURL, nil, nil)
+}
+
+// BuildStatRequest 构造 Stat 请求但不发送，返回的请求已经解析了域名并添加了签名，可以由代理等转发或者检查。
+// 签名覆盖请求的方法、URL 以及 Content-Type 等请求头，转发时不能修改，否则签名校验会失败
+func (m *BucketManager) BuildStatRequest(ctx context.Context, bucket, key string) (*http.Request, error) {
+	return m.buildEntryRequest(ctx, HostCategoryRs, URIStat(bucket, key), BucketKey{bucket, key})
+}
+
+// BuildDeleteRequest 构造 Delete 请求但不发送，参见 BuildStatRequest
+func (m *BucketManager) BuildDeleteRequest(ctx context.Context, bucket, key string) (*http.Request, error) {
+	return m.buildEntryRequest(ctx, HostCategoryRs, URIDelete(bucket, key), BucketKey{bucket, key})
+}
+
+// BuildCopyRequest 构造 Copy 请求但不发送，参见 BuildStatRequest
+func (m *BucketManager) BuildCopyRequest(ctx context.Context, srcBucket, srcKey, destBucket, destKey string, force bool) (*http.Request, error) {
+	return m.buildEntryRequest(ctx, HostCategoryRs, URICopy(srcBucket, srcKey, destBucket, destKey, force),
+		BucketKey{srcBucket, srcKey}, BucketKey{destBucket, destKey})
+}
+
+// BuildMoveRequest 构造 Move 请求但不发送，参见 BuildStatRequest
+func (m *BucketManager) BuildMoveRequest(ctx context.Context, srcBucket, srcKey, destBucket, destKey string, force bool) (*http.Request, error) {
+	return m.buildEntryRequest(ctx, HostCategoryRs, URIMove(srcBucket, srcKey, destBucket, destKey, force),
+		BucketKey{srcBucket, srcKey}, BucketKey{destBucket, destKey})
+}
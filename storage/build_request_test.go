@@ -0,0 +1,81 @@
+// +build unit
+
+package storage
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestBuildRequest(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []*http.Request
+	)
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"hash":"h"}`))
+	})
+	defer srv.Close()
+
+	if _, err := m.Stat("bucket", "key"); err != nil {
+		t.Fatal(err)
+	}
+	req, err := m.BuildStatRequest(context.Background(), "bucket", "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	mu.Lock()
+	defer mu.Unlock()
+	// 签名包含 X-Qiniu-Date，两次签名的时间不同时 Authorization 也不同，因此只比较签名的前缀
+	if len(requests) != 2 || requests[1].Method != requests[0].Method || requests[1].URL.String() != requests[0].URL.String() ||
+		headerNames(requests[1].Header) != headerNames(requests[0].Header) ||
+		!strings.HasPrefix(requests[1].Header.Get("Authorization"), "Qiniu ak:") {
+		t.Fatalf("built request differs from Stat: %s %v, %v", requests[1].Method, requests[1].URL, requests[1].Header)
+	}
+
+	for _, build := range []func() (*http.Request, error){
+		func() (*http.Request, error) { return m.BuildDeleteRequest(context.Background(), "bucket", "key") },
+		func() (*http.Request, error) {
+			return m.BuildCopyRequest(context.Background(), "src", "a", "dest", "b", true)
+		},
+		func() (*http.Request, error) {
+			return m.BuildMoveRequest(context.Background(), "src", "a", "dest", "b", false)
+		},
+	} {
+		req, err := build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.Method != "POST" || !strings.HasPrefix(req.URL.String(), srv.URL+"/") || req.Header.Get("Authorization") == "" {
+			t.Fatalf("unexpected request: %s %s %v", req.Method, req.URL, req.Header)
+		}
+	}
+
+	m.Cfg.AutoValidateKeys = true
+	if _, err = m.BuildStatRequest(context.Background(), "bucket", "\xff"); err == nil {
+		t.Fatal("expect error for invalid key")
+	}
+}
+
+// headerNames 返回请求头中所有字段的名称，按照字母顺序排列
+func headerNames(header http.Header) string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
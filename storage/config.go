@@ -1,5 +1,9 @@
 package storage
 
+import (
+	"time"
+)
+
 // Config 为文件上传，资源管理等配置
 type Config struct {
 	//兼容保留
@@ -12,6 +16,12 @@ type Config struct {
 	UseHTTPS      bool   //是否使用https域名
 	UseCdnDomains bool   //是否使用cdn加速域名
 	CentralRsHost string //中心机房的RsHost，用于list bucket
+	CentralUcHost string //中心机房的UcHost，用于 Buckets、CreateBucket 等账号级别的操作，为空时使用 UcReqHost
+
+	// BatchHost 批量操作（/batch）使用的 rs 服务地址，格式与 CentralRsHost 相同，
+	// 只影响 Batch、BatchStat 等批量操作，stat、delete 等单个文件的操作不受影响。
+	// 优先级：BatchHost 不为空时使用 BatchHost，否则使用 CentralRsHost，都为空时使用 DefaultRsHost
+	BatchHost string
 
 	// 兼容保留
 	RsHost  string
@@ -19,6 +29,60 @@ type Config struct {
 	UpHost  string
 	ApiHost string
 	IoHost  string
+	UcHost  string
+
+	// MimeOverrides 自定义的扩展名到 MimeType 的映射，如 ".glb": "model/gltf-binary"
+	// 扩展名不区分大小写，可以省略开头的 "."，优先级高于系统的 mime 表
+	MimeOverrides map[string]string
+
+	// UseJSONNumber 为 true 时，列举等接口解析响应使用 json.Decoder.UseNumber，
+	// 解析到 interface{} 中的数字会保留为 json.Number，避免大整数（如 putTime）转换为 float64 后丢失精度
+	UseJSONNumber bool
+
+	// DialTimeout 和 TLSHandshakeTimeout 分别为建立连接和 TLS 握手的超时时间，不包括读取响应的时间，
+	// 任意一个不为 0 时，NewBucketManager 会创建使用该超时时间的独立连接池，为 0 时使用默认值
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+
+	// DisableCompression 为 true 时管理请求使用 Accept-Encoding: identity，并且不再自动解压 gzip 响应，
+	// 用于会对响应额外进行 gzip 压缩的代理环境，NewBucketManager 会为此创建独立的连接池
+	DisableCompression bool
+
+	// DisableZoneDiscovery 为 true 时不再通过 uc 服务查询空间所在的区域，
+	// 需要配置 Zone/Region 或者操作所需的 RsHost、RsfHost、IoHost、ApiHost，未配置时返回错误，用于无法访问 uc 服务的网络环境
+	DisableZoneDiscovery bool
+
+	// DefaultBucket 默认的空间，用于 StatKey、DeleteKey 等不需要指定空间的方法
+	DefaultBucket string
+
+	// RetryPolicy 服务端限流（429、503）时的重试策略，为 nil 时不重试，参见 RetryPolicy
+	RetryPolicy *RetryPolicy
+
+	// Tracer 为每次资源管理调用创建追踪 span，为 nil 时不做任何处理，参见 Tracer
+	Tracer Tracer
+
+	// BatchBodyLimit 批量操作请求体大小的上限，单位：字节，为 0 时不检查。超过时返回 *BatchBodyTooLargeError，
+	// BatchAutoSplit 为 true 时则自动拆分为多个请求，请求体的大小参见 EstimateBatchSize
+	BatchBodyLimit int
+	BatchAutoSplit bool
+
+	// OnBatchConcurrency 在 BatchFromChan、BatchAll 由于限流（429 或 573）调整同时发送的批数时调用，concurrency 为调整后的并发数
+	OnBatchConcurrency func(concurrency int)
+
+	// EntryCacheSize 大于 0 时，Stat 和 Delete 会缓存最近使用的 EncodedEntry 结果，最多 EntryCacheSize 个。
+	// 只对反复操作少量固定文件的热点循环有帮助，一般情况下不需要设置，需要在 NewBucketManager 之前设置
+	EntryCacheSize int
+
+	// AutoValidateKeys 为 true 时，在发送请求前检查文件名是否为合法的 UTF-8 字符串，不合法时直接返回 ErrKeyNotUTF8
+	AutoValidateKeys bool
+
+	// BucketInfoCacheTTL 大于 0 时，IsPublic、URLBuilder、AssertRegion 会缓存空间信息 BucketInfoCacheTTL 时长，减少 uc 请求。
+	// 通过同一个 BucketManager 修改空间配置（如 MakeBucketPrivate）成功后会自动删除缓存，参见 InvalidateBucketInfo，
+	// 需要在 NewBucketManager 之前设置
+	BucketInfoCacheTTL time.Duration
+
+	// EscapePolicy Download、URLBuilder 等方法生成下载链接时文件名的 escape 规则，为 nil 时使用 DefaultEscapePolicy
+	EscapePolicy *EscapePolicy
 }
 
 // reqHost 返回一个Host链接
@@ -41,6 +105,14 @@ func (c *Config) RsReqHost() string {
 	return reqHost(c.UseHTTPS, rzHost, c.RsHost, DefaultRsHost)
 }
 
+// batchHost 返回批量操作使用的 rs 服务地址以及该地址对应的配置项名称
+func (c *Config) batchHost() (host, field string) {
+	if c.BatchHost != "" {
+		return c.BatchHost, "BatchHost"
+	}
+	return c.CentralRsHost, "CentralRsHost"
+}
+
 // GetRegion返回一个Region指针
 // 默认返回最新的Region， 如果该字段没有，那么返回兼容保留的Zone, 如果都为nil, 就返回nil
 func (c *Config) GetRegion() *Region {
@@ -77,6 +149,24 @@ func (c *Config) RsfReqHost() string {
 	return reqHost(c.UseHTTPS, rsHost, c.RsfHost, DefaultRsfHost)
 }
 
+// 获取ucHost
+// 优先使用Config中的UcHost，如果没有配置，那么使用 SetUcHost 或 UcHost 设置的全局Host信息
+// Region 中没有 uc 服务的域名，因此与 Region 的配置无关
+func (c *Config) UcReqHost() string {
+	if c.UcHost != "" {
+		return endpoint(c.UseHTTPS, c.UcHost)
+	}
+	return getUcHost(c.UseHTTPS)
+}
+
+// centralUcReqHost 返回账号级别操作使用的 uc 服务地址，优先使用 CentralUcHost
+func (c *Config) centralUcReqHost() string {
+	if c.CentralUcHost != "" {
+		return endpoint(c.UseHTTPS, c.CentralUcHost)
+	}
+	return c.UcReqHost()
+}
+
 // 获取apiHost
 // 优先使用Zone中的Host信息，如果Zone中的host信息没有配置，那么使用Config中的Host信息
 func (c *Config) ApiReqHost() string {
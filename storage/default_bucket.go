@@ -0,0 +1,36 @@
+package storage
+
+// defaultBucket 返回 Config.DefaultBucket，没有设置时返回 ErrDefaultBucketNotSet
+func (m *BucketManager) defaultBucket() (bucket string, err error) {
+	if m.Cfg.DefaultBucket == "" {
+		return "", ErrDefaultBucketNotSet
+	}
+	return m.Cfg.DefaultBucket, nil
+}
+
+// StatKey 与 Stat 相同，获取默认空间 Config.DefaultBucket 中文件的信息
+func (m *BucketManager) StatKey(key string) (info FileInfo, err error) {
+	bucket, err := m.defaultBucket()
+	if err != nil {
+		return
+	}
+	return m.Stat(bucket, key)
+}
+
+// ExistsKey 与 Exists 相同，判断默认空间 Config.DefaultBucket 中的文件是否存在
+func (m *BucketManager) ExistsKey(key string) (exists bool, err error) {
+	bucket, err := m.defaultBucket()
+	if err != nil {
+		return
+	}
+	return m.Exists(bucket, key)
+}
+
+// DeleteKey 与 Delete 相同，删除默认空间 Config.DefaultBucket 中的文件
+func (m *BucketManager) DeleteKey(key string) (err error) {
+	bucket, err := m.defaultBucket()
+	if err != nil {
+		return
+	}
+	return m.Delete(bucket, key)
+}
@@ -0,0 +1,313 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qiniu/go-sdk/v7/auth"
+	"github.com/qiniu/go-sdk/v7/client"
+)
+
+// downloadURLExpiry 下载文件时生成的私有链接的有效期
+const downloadURLExpiry = time.Hour
+
+// restorePollInterval ReadArchived 查询解冻状态的时间间隔
+var restorePollInterval = 30 * time.Second
+
+// progressInterval DownloadToFile 回调下载进度的最小时间间隔
+var progressInterval = time.Second
+
+// progressWindow 计算下载速度时使用的时间窗口
+const progressWindow = 5 * time.Second
+
+// downloadDomain domain 没有指定协议时根据 Config.UseHTTPS 决定使用 http 还是 https
+func (m *BucketManager) downloadDomain(domain string) string {
+	if !strings.Contains(domain, "://") {
+		if m.Cfg.UseHTTPS {
+			return "https://" + domain
+		}
+		return "http://" + domain
+	}
+	return domain
+}
+
+// makeDownloadURL 生成文件的私有下载链接，文件名按照 Config.EscapePolicy 进行 escape
+func (m *BucketManager) makeDownloadURL(domain, key string) string {
+	return MakePrivateURLWithPolicy(m.Mac, m.downloadDomain(domain), key, m.Cfg.EscapePolicy, Deadline(downloadURLExpiry))
+}
+
+// Download 通过空间绑定的域名 domain 下载文件，返回文件内容，调用方需要关闭返回的 io.ReadCloser
+// 下载使用私有链接，对公开空间同样有效
+func (m *BucketManager) Download(ctx context.Context, domain, key string) (body io.ReadCloser, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	resp, err := m.Client.DoRequest(ctx, "GET", m.makeDownloadURL(domain, key), nil)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode/100 != 2 {
+		err = client.ResponseError(resp)
+		resp.Body.Close()
+		return
+	}
+	body = resp.Body
+	return
+}
+
+// DownloadRange 使用 mac 签名私有链接，通过空间绑定的域名 domain 下载文件中 [start, end] 范围内的内容（包含 end），
+// deadline 为链接的过期时间戳。适用于只需要文件开头部分的场景，如预览大文件或者读取媒体文件的头部信息。
+// 范围超出文件大小时返回 ErrRangeNotSatisfiable，调用方需要关闭返回的 io.ReadCloser
+func (m *BucketManager) DownloadRange(ctx context.Context, mac *auth.Credentials, domain, key string, start, end int64, deadline int64) (body io.ReadCloser, err error) {
+	if start < 0 || start > end {
+		return nil, fmt.Errorf("invalid range: %d-%d", start, end)
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	privateURL := MakePrivateURLWithPolicy(mac, m.downloadDomain(domain), key, m.Cfg.EscapePolicy, deadline)
+	headers := http.Header{}
+	headers.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	resp, err := m.Client.DoRequest(ctx, "GET", privateURL, headers)
+	if err != nil {
+		return
+	}
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		body = resp.Body
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		resp.Body.Close()
+		err = ErrRangeNotSatisfiable
+	case resp.StatusCode/100 == 2:
+		// 服务端忽略了 Range 头并返回了整个文件，跳过 start 之前的内容，并只返回范围内的部分
+		if _, err = io.CopyN(ioutil.Discard, resp.Body, start); err != nil {
+			resp.Body.Close()
+			if err == io.EOF {
+				err = ErrRangeNotSatisfiable
+			}
+			return
+		}
+		body = struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(resp.Body, end-start+1), resp.Body}
+	default:
+		err = client.ResponseError(resp)
+		resp.Body.Close()
+	}
+	return
+}
+
+// ReadArchived 读取归档或深度归档存储的文件：文件未解冻时先调用 RestoreAr 解冻（解冻有效期为 freezeAfterDays 天），
+// 然后定期查询直到解冻完成，最后通过 domain 下载文件。标准存储和低频存储的文件会直接下载。
+// 发起解冻后 stat 的结果可能暂时仍然是冻结状态，此时会继续查询而不会重复解冻。
+// 解冻通常需要数分钟甚至数小时，可以通过 ctx 设置等待的超时时间，ctx 同时用于每一次请求
+func (m *BucketManager) ReadArchived(ctx context.Context, bucket, key, domain string, freezeAfterDays int) (body io.ReadCloser, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	defer func() {
+		// ctx 取消导致的请求失败统一返回 ctx.Err()
+		if err != nil && ctx.Err() != nil {
+			body, err = nil, ctx.Err()
+		}
+	}()
+
+	restoreIssued := false
+	for {
+		var info FileInfo
+		if err = m.entryCall(ctx, HostCategoryRs, &info, URIStat(bucket, key), BucketKey{bucket, key}); err != nil {
+			return
+		}
+		if !info.IsFrozen() && !info.IsRestoring() {
+			break
+		}
+		if info.IsFrozen() && !restoreIssued {
+			if err = m.entryCall(ctx, HostCategoryRs, nil, URIRestoreAr(bucket, key, freezeAfterDays), BucketKey{bucket, key}); err != nil {
+				return
+			}
+			restoreIssued = true
+		}
+
+		timer := time.NewTimer(restorePollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return m.Download(ctx, domain, key)
+}
+
+// DownloadOpts 下载文件到本地的可选参数
+type DownloadOpts struct {
+	// OnProgress 下载进度回调，written 为已下载的字节数，total 为文件总大小（未知时为 -1），
+	// bytesPerSec 为最近几秒内的下载速度。下载过程中最多每秒回调一次，下载完成时总会回调一次
+	OnProgress func(written, total int64, bytesPerSec float64)
+}
+
+// DownloadToFile 通过空间绑定的域名 domain 下载文件并保存到本地文件 localFile，返回下载的字节数，
+// 下载失败时会删除已经写入的本地文件
+func (m *BucketManager) DownloadToFile(ctx context.Context, domain, key, localFile string, opts *DownloadOpts) (written int64, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if opts == nil {
+		opts = &DownloadOpts{}
+	}
+	resp, err := m.Client.DoRequest(ctx, "GET", m.makeDownloadURL(domain, key), nil)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		err = client.ResponseError(resp)
+		return
+	}
+
+	f, err := os.Create(localFile)
+	if err != nil {
+		return
+	}
+	var w io.Writer = f
+	var progress *downloadProgress
+	if opts.OnProgress != nil {
+		progress = newDownloadProgress(opts.OnProgress, resp.ContentLength)
+		w = io.MultiWriter(f, progress)
+	}
+	written, err = io.Copy(w, resp.Body)
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		os.Remove(localFile)
+		return
+	}
+	if progress != nil {
+		progress.report(time.Now())
+	}
+	return
+}
+
+// DownloadJob DownloadMany 的一个下载任务
+type DownloadJob struct {
+	Key       string
+	LocalPath string
+}
+
+// DownloadResult DownloadMany 中一个下载任务的结果，Err 不为 nil 时表示该任务失败
+type DownloadResult struct {
+	Key       string
+	LocalPath string
+	Written   int64
+	Err       error
+}
+
+// DownloadMany 使用最多 concurrency 个 goroutine 通过空间绑定的域名 domain 下载多个文件到本地，每个文件的下载方式与 DownloadToFile 相同。
+// 返回的结果与 jobs 一一对应，单个任务失败不影响其他任务，失败原因记录在对应结果的 Err 中。
+// ctx 取消后不再开始新的任务，未开始的任务的 Err 为 ctx.Err()，同时返回 ctx.Err()
+func (m *BucketManager) DownloadMany(ctx context.Context, domain string, jobs []DownloadJob, concurrency int) (results []DownloadResult, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	results = make([]DownloadResult, len(jobs))
+	for i, job := range jobs {
+		results[i] = DownloadResult{Key: job.Key, LocalPath: job.LocalPath}
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	if concurrency > len(jobs) {
+		concurrency = len(jobs)
+	}
+
+	var (
+		wg     sync.WaitGroup
+		indexC = make(chan int)
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexC {
+				result := &results[index]
+				result.Written, result.Err = m.DownloadToFile(ctx, domain, result.Key, result.LocalPath, nil)
+			}
+		}()
+	}
+
+	sent := 0
+send:
+	for ; sent < len(jobs); sent++ {
+		select {
+		case indexC <- sent:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(indexC)
+	wg.Wait()
+
+	if err = ctx.Err(); err != nil {
+		for i := sent; i < len(jobs); i++ {
+			results[i].Err = err
+		}
+	}
+	return
+}
+
+type progressSample struct {
+	at      time.Time
+	written int64
+}
+
+// downloadProgress 统计下载进度，按照 progressInterval 节流回调，并使用最近 progressWindow 内的数据计算下载速度
+type downloadProgress struct {
+	onProgress func(written, total int64, bytesPerSec float64)
+	total      int64
+	written    int64
+	lastReport time.Time
+	samples    []progressSample
+}
+
+func newDownloadProgress(onProgress func(written, total int64, bytesPerSec float64), total int64) *downloadProgress {
+	now := time.Now()
+	return &downloadProgress{
+		onProgress: onProgress,
+		total:      total,
+		lastReport: now,
+		samples:    []progressSample{{at: now}},
+	}
+}
+
+func (p *downloadProgress) Write(b []byte) (int, error) {
+	p.written += int64(len(b))
+	if now := time.Now(); now.Sub(p.lastReport) >= progressInterval {
+		p.report(now)
+	}
+	return len(b), nil
+}
+
+func (p *downloadProgress) report(now time.Time) {
+	p.lastReport = now
+	p.samples = append(p.samples, progressSample{at: now, written: p.written})
+	// 保留时间窗口之前的最后一个样本作为计算速度的起点
+	for len(p.samples) > 2 && now.Sub(p.samples[1].at) >= progressWindow {
+		p.samples = p.samples[1:]
+	}
+
+	var speed float64
+	oldest := p.samples[0]
+	if elapsed := now.Sub(oldest.at).Seconds(); elapsed > 0 {
+		speed = float64(p.written-oldest.written) / elapsed
+	}
+	p.onProgress(p.written, p.total, speed)
+}
@@ -0,0 +1,270 @@
+// +build unit
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadArchived(t *testing.T) {
+	defer func(interval time.Duration) {
+		restorePollInterval = interval
+	}(restorePollInterval)
+	restorePollInterval = time.Millisecond
+
+	var (
+		restoreStatus = 0
+		staleStats    = 0
+		paths         []string
+	)
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, strings.SplitN(r.URL.Path[1:], "/", 2)[0])
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/stat/"):
+			w.Header().Set("Content-Type", "application/json")
+			if staleStats > 0 {
+				// 发起解冻后 stat 暂时仍然返回冻结状态
+				staleStats--
+				w.Write([]byte(`{"type":2,"restoreStatus":0}`))
+				return
+			}
+			fmt.Fprintf(w, `{"type":2,"restoreStatus":%d}`, restoreStatus)
+			if restoreStatus == 1 {
+				restoreStatus = 2
+			}
+		case strings.HasPrefix(r.URL.Path, "/restoreAr/"):
+			restoreStatus, staleStats = 1, 2
+		case r.URL.Path == "/archived":
+			if r.URL.Query().Get("e") == "" || r.URL.Query().Get("token") == "" {
+				t.Errorf("download url should be signed: %s", r.URL)
+			}
+			w.Write([]byte("content"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer srv.Close()
+
+	body, err := m.ReadArchived(context.Background(), "bucket", "archived", srv.URL, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	data, _ := ioutil.ReadAll(body)
+	if string(data) != "content" {
+		t.Fatalf("unexpected content: %s", data)
+	}
+	if want := "stat,restoreAr,stat,stat,stat,stat,archived"; strings.Join(paths, ",") != want {
+		t.Fatalf("want = %s, got = %v", want, paths)
+	}
+
+	if _, err = m.Download(context.Background(), srv.URL, "missing"); err == nil {
+		t.Fatal("expect error for missing file")
+	}
+
+	restoreStatus = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = m.ReadArchived(ctx, "bucket", "archived", srv.URL, 1); err != context.Canceled {
+		t.Fatalf("want context.Canceled, got %v", err)
+	}
+}
+
+func TestReadArchivedStaleStat(t *testing.T) {
+	defer func(interval time.Duration) {
+		restorePollInterval = interval
+	}(restorePollInterval)
+	restorePollInterval = time.Millisecond
+
+	var restores int32
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/restoreAr/") {
+			atomic.AddInt32(&restores, 1)
+			return
+		}
+		// 解冻状态一直没有更新
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"type":2,"restoreStatus":0}`))
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := m.ReadArchived(ctx, "bucket", "archived", srv.URL, 1); err != context.DeadlineExceeded {
+		t.Fatalf("want context.DeadlineExceeded, got %v", err)
+	}
+	if n := atomic.LoadInt32(&restores); n != 1 {
+		t.Fatalf("restoreAr should be called once, got %d", n)
+	}
+}
+
+func TestDownloadToFile(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/file" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("content"))
+	})
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var calls, lastWritten, lastTotal int64
+	localFile := filepath.Join(dir, "file")
+	written, err := m.DownloadToFile(context.Background(), srv.URL, "file", localFile, &DownloadOpts{
+		OnProgress: func(written, total int64, bytesPerSec float64) {
+			calls++
+			lastWritten, lastTotal = written, total
+		},
+	})
+	if err != nil || written != 7 {
+		t.Fatalf("written = %d, err = %v", written, err)
+	}
+	if data, _ := ioutil.ReadFile(localFile); string(data) != "content" {
+		t.Fatalf("unexpected content: %s", data)
+	}
+	if calls != 1 || lastWritten != 7 || lastTotal != 7 {
+		t.Fatalf("calls = %d, written = %d, total = %d", calls, lastWritten, lastTotal)
+	}
+
+	if _, err = m.DownloadToFile(context.Background(), srv.URL, "missing", filepath.Join(dir, "missing"), nil); err == nil {
+		t.Fatal("expect error for missing file")
+	}
+	if _, sErr := os.Stat(filepath.Join(dir, "missing")); !os.IsNotExist(sErr) {
+		t.Fatalf("local file should not be created: %v", sErr)
+	}
+}
+
+func TestDownloadProgressRollingWindow(t *testing.T) {
+	var speed float64
+	p := newDownloadProgress(func(written, total int64, bytesPerSec float64) { speed = bytesPerSec }, -1)
+	start := p.samples[0].at
+
+	// 前 10 秒每秒 1000 字节，之后每秒 100 字节，速度应当只反映最近的情况
+	for i := 1; i <= 10; i++ {
+		p.written += 1000
+		p.report(start.Add(time.Duration(i) * time.Second))
+	}
+	if speed != 1000 {
+		t.Fatalf("speed = %v, want 1000", speed)
+	}
+	for i := 11; i <= 20; i++ {
+		p.written += 100
+		p.report(start.Add(time.Duration(i) * time.Second))
+	}
+	if speed != 100 {
+		t.Fatalf("speed = %v, want 100", speed)
+	}
+}
+
+func TestDownloadRange(t *testing.T) {
+	const content = "0123456789"
+	ignoreRange := false
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("token") == "" {
+			t.Errorf("download url should be signed: %s", r.URL)
+		}
+		if ignoreRange {
+			r.Header.Del("Range")
+		}
+		http.ServeContent(w, r, "file", time.Time{}, strings.NewReader(content))
+	})
+	defer srv.Close()
+	mac := m.Mac
+	deadline := Deadline(time.Hour)
+
+	for _, c := range []struct {
+		start, end  int64
+		ignoreRange bool
+		want        string
+	}{
+		{0, 3, false, "0123"},
+		{4, 20, false, "456789"},
+		{2, 4, true, "234"},
+	} {
+		ignoreRange = c.ignoreRange
+		body, err := m.DownloadRange(context.Background(), mac, srv.URL, "file", c.start, c.end, deadline)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadAll(body)
+		body.Close()
+		if string(data) != c.want {
+			t.Fatalf("range %d-%d: got %q, want %q", c.start, c.end, data, c.want)
+		}
+	}
+
+	ignoreRange = false
+	if _, err := m.DownloadRange(context.Background(), mac, srv.URL, "file", 20, 30, deadline); err != ErrRangeNotSatisfiable {
+		t.Fatalf("want ErrRangeNotSatisfiable, got %v", err)
+	}
+	if _, err := m.DownloadRange(context.Background(), mac, srv.URL, "file", 5, 4, deadline); err == nil {
+		t.Fatal("expect error for invalid range")
+	}
+}
+
+func TestDownloadMany(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(r.URL.Path[1:]))
+	})
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keys := []string{"a", "missing", "bb", "ccc"}
+	jobs := make([]DownloadJob, len(keys))
+	for i, key := range keys {
+		jobs[i] = DownloadJob{Key: key, LocalPath: filepath.Join(dir, key)}
+	}
+	results, err := m.DownloadMany(context.Background(), srv.URL, jobs, 2)
+	if err != nil || len(results) != len(keys) {
+		t.Fatalf("results = %+v, err = %v", results, err)
+	}
+	for i, result := range results {
+		if result.Key != keys[i] {
+			t.Fatalf("results[%d].Key = %s, want %s", i, result.Key, keys[i])
+		}
+		if (result.Err != nil) != (result.Key == "missing") {
+			t.Fatalf("results[%d].Err = %v", i, result.Err)
+		}
+		if result.Err == nil {
+			if data, _ := ioutil.ReadFile(result.LocalPath); string(data) != result.Key || result.Written != int64(len(data)) {
+				t.Fatalf("results[%d]: content = %s, written = %d", i, data, result.Written)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err = m.DownloadMany(ctx, srv.URL, jobs, 2)
+	if err != context.Canceled || len(results) != len(keys) {
+		t.Fatalf("results = %+v, err = %v", results, err)
+	}
+	for i, result := range results {
+		if result.Err == nil {
+			t.Fatalf("results[%d] should fail after cancel", i)
+		}
+	}
+}
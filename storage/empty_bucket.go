@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+// EmptyBucket 删除空间中的所有文件，返回删除成功的文件数量，用于测试环境的清理。
+// 删除前会先列举空间统计文件数量，并调用 confirm，只有 confirm 返回 true 时才会开始删除，confirm 不能为 nil。
+// 删除时边列举边按照每批 1000 个文件批量删除，删除期间新上传的文件也可能被删除；
+// 单个文件删除失败不会中断删除，但会在最后返回 KeyErrors
+func (m *BucketManager) EmptyBucket(ctx context.Context, bucket string, confirm func(bucket string, estimatedCount int64) bool) (deleted int64, err error) {
+	if confirm == nil {
+		return 0, errors.New("confirm func is required")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	report, err := m.GetStorageReport(ctx, bucket, "")
+	if err != nil {
+		return
+	}
+	if !confirm(bucket, report.TotalCount) {
+		return 0, nil
+	}
+
+	keyErrs := make(KeyErrors)
+	keys := make([]string, 0, batchLimit)
+	flush := func() error {
+		results, dErr := m.DeleteMany(ctx, bucket, keys)
+		for key, rErr := range results {
+			if rErr == nil {
+				deleted++
+			} else if rErr != ErrNoSuchEntry {
+				keyErrs[key] = rErr
+			}
+		}
+		keys = keys[:0]
+		return dErr
+	}
+	err = m.WalkPrefix(ctx, bucket, "", nil, func(item ListItem) error {
+		if keys = append(keys, item.Key); len(keys) == batchLimit {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil && len(keys) > 0 {
+		err = flush()
+	}
+	if err == nil && len(keyErrs) > 0 {
+		err = keyErrs
+	}
+	return
+}
@@ -0,0 +1,65 @@
+// +build unit
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestEmptyBucket(t *testing.T) {
+	files := map[string]bool{}
+	for i := 0; i < 1500; i++ {
+		files[fmt.Sprintf("key%04d", i)] = true
+	}
+	var batches []int
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/list":
+			items := make([]string, 0, len(files))
+			for key := range files {
+				items = append(items, `{"key":"`+key+`"}`)
+			}
+			w.Write([]byte(`{"items":[` + strings.Join(items, ",") + `]}`))
+		case "/batch":
+			r.ParseForm()
+			batches = append(batches, len(r.PostForm["op"]))
+			rets := make([]string, 0, len(r.PostForm["op"]))
+			for _, op := range r.PostForm["op"] {
+				if op == URIDelete("bucket", "key0001") {
+					rets = append(rets, `{"code":403,"data":{"error":"forbidden"}}`)
+					continue
+				}
+				rets = append(rets, `{"code":200}`)
+			}
+			w.Write([]byte("[" + strings.Join(rets, ",") + "]"))
+		}
+	})
+	defer srv.Close()
+
+	var estimated int64
+	deleted, err := m.EmptyBucket(context.Background(), "bucket", func(bucket string, count int64) bool {
+		estimated = count
+		return false
+	})
+	if err != nil || deleted != 0 || estimated != 1500 || len(batches) != 0 {
+		t.Fatalf("deleted = %d, estimated = %d, batches = %v, err = %v", deleted, estimated, batches, err)
+	}
+
+	deleted, err = m.EmptyBucket(context.Background(), "bucket", func(string, int64) bool { return true })
+	keyErrs, ok := err.(KeyErrors)
+	if !ok || len(keyErrs) != 1 || keyErrs["key0001"] == nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if deleted != 1499 || len(batches) != 2 || batches[0] != 1000 || batches[1] != 500 {
+		t.Fatalf("deleted = %d, batches = %v", deleted, batches)
+	}
+
+	if _, err = m.EmptyBucket(context.Background(), "bucket", nil); err == nil {
+		t.Fatal("expect error for nil confirm")
+	}
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"container/list"
+	"sync"
+)
+
+// entryCache 缓存 EncodedEntry 结果的 LRU，最多保存 size 个 entry，可以被多个 goroutine 同时使用。
+//
+// 根据 BenchmarkEncodedEntry，编码一个 entry 约需要 500ns 和 4 次内存分配，命中缓存时约 70ns 且没有内存分配，
+// 但与一次网络请求（通常为毫秒级）相比都可以忽略不计，只有在对固定的少量文件反复调用 Stat、Delete 的热点循环中
+// 才能观察到 CPU 和 GC 开销的减少，一般情况下不需要开启
+type entryCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[BucketKey]*list.Element
+}
+
+type entryCacheItem struct {
+	entry   BucketKey
+	encoded string
+}
+
+func newEntryCache(size int) *entryCache {
+	return &entryCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[BucketKey]*list.Element, size),
+	}
+}
+
+// get 返回 bucket 和 key 对应的 EncodedEntry，没有缓存时编码并加入缓存，超过容量时淘汰最久没有使用的 entry
+func (c *entryCache) get(bucket, key string) string {
+	entry := BucketKey{bucket, key}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[entry]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*entryCacheItem).encoded
+	}
+	encoded := EncodedEntry(bucket, key)
+	c.items[entry] = c.ll.PushFront(&entryCacheItem{entry: entry, encoded: encoded})
+	if c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*entryCacheItem).entry)
+	}
+	return encoded
+}
+
+// cachedEncodedEntry 与 EncodedEntry 相同，Config.EntryCacheSize 大于 0 时使用缓存的结果
+func (m *BucketManager) cachedEncodedEntry(bucket, key string) string {
+	if m.entries == nil {
+		return EncodedEntry(bucket, key)
+	}
+	return m.entries.get(bucket, key)
+}
@@ -0,0 +1,54 @@
+// +build unit
+
+package storage
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestEntryCache(t *testing.T) {
+	c := newEntryCache(2)
+	if c.get("bucket", "a") != EncodedEntry("bucket", "a") || c.get("bucket", "b") != EncodedEntry("bucket", "b") {
+		t.Fatal("unexpected encoded entry")
+	}
+	c.get("bucket", "a")
+	c.get("bucket", "c")
+	if len(c.items) != 2 {
+		t.Fatalf("len = %d, want 2", len(c.items))
+	}
+	if _, ok := c.items[BucketKey{"bucket", "b"}]; ok {
+		t.Fatal("least recently used entry should be evicted")
+	}
+	if _, ok := c.items[BucketKey{"bucket", "a"}]; !ok {
+		t.Fatal("recently used entry should be kept")
+	}
+
+	m := NewBucketManager(nil, &Config{EntryCacheSize: 10})
+	if m.entries == nil || m.cachedEncodedEntry("bucket", "key") != EncodedEntry("bucket", "key") {
+		t.Fatal("entry cache should be enabled")
+	}
+	if m = NewBucketManager(nil, &Config{}); m.entries != nil {
+		t.Fatal("entry cache should be disabled by default")
+	}
+}
+
+func BenchmarkEncodedEntry(b *testing.B) {
+	keys := make([]string, 16)
+	for i := range keys {
+		keys[i] = "path/to/hot/key-" + strconv.Itoa(i)
+	}
+	b.Run("Uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			EncodedEntry("bucket", keys[i%len(keys)])
+		}
+	})
+	b.Run("Cached", func(b *testing.B) {
+		c := newEntryCache(len(keys))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			c.get("bucket", keys[i%len(keys)])
+		}
+	})
+}
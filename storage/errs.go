@@ -2,12 +2,175 @@ package storage
 
 import (
 	"errors"
+	"fmt"
 )
 
 var (
 	// ErrBucketNotExist 用户存储空间不存在
 	ErrBucketNotExist = errors.New("bucket not exist")
 
-	// ErrNoSuchFile 文件已经存在
+	// ErrNoSuchBucket 空间不存在，与 ErrBucketNotExist 相同，由 CheckBucketAccess 等方法返回。
+	// 其他方法遇到服务端返回的 631 错误时仍然返回 *ErrorInfo，可以使用 IsNoSuchBucket 统一判断
+	ErrNoSuchBucket = ErrBucketNotExist
+
+	// ErrZoneDiscoveryDisabled 禁用了区域自动查询，并且没有配置所需的区域或者 Host
+	ErrZoneDiscoveryDisabled = errors.New("zone discovery is disabled")
+
+	// ErrDefaultBucketNotSet 没有设置 Config.DefaultBucket
+	ErrDefaultBucketNotSet = errors.New("Config.DefaultBucket is not set")
+
+	// ErrAccessDenied 没有访问权限，如 AccessKey/SecretKey 错误或者没有空间的访问权限
+	ErrAccessDenied = errors.New("access denied")
+
+	// ErrNoSuchFile 文件不存在
 	ErrNoSuchFile = errors.New("No such file or directory")
+
+	// ErrNoSuchEntry 文件不存在（服务端错误码 612），与 ErrNoSuchFile 相同
+	ErrNoSuchEntry = ErrNoSuchFile
+
+	// ErrConditionNotMet 文件不满足操作的前置条件，如文件的 hash 与预期不一致
+	ErrConditionNotMet = errors.New("condition not met")
+
+	// ErrSameStorageType 文件已经是目标存储类型
+	ErrSameStorageType = errors.New("already in the target storage type")
+
+	// ErrInvalidMarkerToken 列举位置令牌格式不正确或者签名校验失败
+	ErrInvalidMarkerToken = errors.New("invalid marker token")
+
+	// ErrTooManyRedirects ResolveFetchURL 解析链接时重定向的次数超过限制
+	ErrTooManyRedirects = errors.New("too many redirects")
+
+	// ErrRangeNotSatisfiable 下载时请求的范围超出了文件大小（服务端返回 416）
+	ErrRangeNotSatisfiable = errors.New("range not satisfiable")
+
+	// ErrBatchBodyTooLarge 批量操作的请求体过大，具体的大小参见 BatchBodyTooLargeError
+	ErrBatchBodyTooLarge = errors.New("batch request body too large")
+
+	// ErrKeyNotUTF8 文件名不是合法的 UTF-8 字符串
+	ErrKeyNotUTF8 = errors.New("key must be utf8 encoding")
+
+	// ErrMd5Mismatch 文件的 md5 与预期不一致
+	ErrMd5Mismatch = errors.New("md5 mismatch")
+
+	// ErrMd5Unavailable 服务端没有返回文件的 md5
+	ErrMd5Unavailable = errors.New("md5 unavailable")
+)
+
+// EntryError 表示针对空间中某个文件的操作失败，Bucket 和 Key 为出错的文件，Err 为具体的错误
+type EntryError struct {
+	Bucket string
+	Key    string
+	Err    error
+}
+
+func (e *EntryError) Error() string {
+	return fmt.Sprintf("%s (bucket: %s, key: %q)", e.Err, e.Bucket, e.Key)
+}
+
+// Unwrap 返回具体的错误，可以使用 errors.Is(err, ErrKeyNotUTF8) 判断错误类型
+func (e *EntryError) Unwrap() error {
+	return e.Err
+}
+
+// BatchBodyTooLargeError 表示批量操作的请求体超过了 Config.BatchBodyLimit，Size 为请求体的大小，参见 EstimateBatchSize
+type BatchBodyTooLargeError struct {
+	Size  int
+	Limit int
+}
+
+func (e *BatchBodyTooLargeError) Error() string {
+	return fmt.Sprintf("%s: %d bytes exceeds the limit of %d bytes", ErrBatchBodyTooLarge, e.Size, e.Limit)
+}
+
+// Unwrap 返回 ErrBatchBodyTooLarge
+func (e *BatchBodyTooLargeError) Unwrap() error {
+	return ErrBatchBodyTooLarge
+}
+
+// RegionMismatchError 表示空间所在的区域与预期的不一致，由 AssertRegion 返回
+type RegionMismatchError struct {
+	Bucket   string
+	Expected RegionID
+	Actual   RegionID
+}
+
+func (e *RegionMismatchError) Error() string {
+	return fmt.Sprintf("bucket %s is in region %s, expected %s", e.Bucket, e.Actual, e.Expected)
+}
+
+// BatchHostError 表示批量操作请求无法发送到 BatchHost 或 CentralRsHost，如域名解析或者连接失败，
+// Host 为实际使用的地址，Field 为该地址对应的配置项名称（"BatchHost" 或 "CentralRsHost"），
+// Default 表示该地址是否为默认值 DefaultRsHost，Err 为底层的网络错误
+type BatchHostError struct {
+	Host    string
+	Field   string
+	Default bool
+	Err     error
+}
+
+func (e *BatchHostError) Error() string {
+	source := "configured"
+	if e.Default {
+		source = "default"
+	}
+	field := e.Field
+	if field == "" {
+		field = "CentralRsHost"
+	}
+	return fmt.Sprintf("batch request to %s %s %s failed: %s", source, field, e.Host, e.Err)
+}
+
+// Unwrap 返回底层的网络错误
+func (e *BatchHostError) Unwrap() error {
+	return e.Err
+}
+
+// 七牛服务端返回的错误码，可以与 ErrorInfo.Code 比较
+const (
+	ErrorCodeConditionNotMet = 608 // 文件内容已被修改，不满足操作的前置条件
+	ErrorCodeNoSuchEntry     = 612 // 指定的资源不存在或已被删除
+	ErrorCodeEntryExists     = 614 // 目标资源已存在
+	ErrorCodeNoSuchBucket    = 631 // 指定空间不存在
+	ErrorCodeInvalidMarker   = 640 // 列举时指定的 marker 无效
+	ErrorCodeTooManyRequests = 573 // 单个资源访问频率过高
+	ErrorCodeCallbackFailed  = 579 // 上传成功但是回调失败
+	ErrorCodeInvalidContext  = 701 // 分片上传的上下文无效或已过期
 )
+
+var errorCodeMessages = map[int]string{
+	ErrorCodeConditionNotMet: "condition not met",
+	ErrorCodeNoSuchEntry:     "no such file or directory",
+	ErrorCodeEntryExists:     "file exists",
+	ErrorCodeNoSuchBucket:    "no such bucket",
+	ErrorCodeInvalidMarker:   "invalid marker",
+	ErrorCodeTooManyRequests: "too many requests",
+	ErrorCodeCallbackFailed:  "callback failed",
+	ErrorCodeInvalidContext:  "invalid upload context",
+}
+
+// errorCode 返回 err 中服务端返回的错误码，err 为 *ErrorInfo 或者包含 *ErrorInfo 的 *EntryError，否则返回 0
+func errorCode(err error) int {
+	if e, ok := err.(*EntryError); ok {
+		err = e.Err
+	}
+	if errInfo, ok := err.(*ErrorInfo); ok {
+		return errInfo.Code
+	}
+	return 0
+}
+
+// IsNoSuchBucket 判断 err 是否表示空间不存在，即服务端返回的错误码为 631，或者 err 为 ErrNoSuchBucket
+func IsNoSuchBucket(err error) bool {
+	return err == ErrNoSuchBucket || errorCode(err) == ErrorCodeNoSuchBucket
+}
+
+// IsInvalidMarker 判断 err 是否表示列举时指定的 marker 无效（服务端返回的错误码为 640），如 marker 已经过期或者格式不正确，
+// 此时需要从头重新列举，不能当作列举结束
+func IsInvalidMarker(err error) bool {
+	return errorCode(err) == ErrorCodeInvalidMarker
+}
+
+// ErrorCodeMessage 返回错误码对应的描述信息，未知的错误码返回空字符串
+func ErrorCodeMessage(code int) string {
+	return errorCodeMessages[code]
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/qiniu/go-sdk/v7/auth"
+)
+
+// EscapePolicy 生成下载链接时文件名的 escape 规则：文件名先经过 url.QueryEscape，再按照 Replacements 中的 (old, new) 对进行替换，规则与 strings.NewReplacer 相同。
+// 不同 CDN 对 '+'、':' 等字符的解码规则不同，文件名包含这些字符时可能需要选择与 CDN 一致的规则，否则会出现 404 或者签名不一致。
+// 为 nil 时与 DefaultEscapePolicy 相同
+type EscapePolicy struct {
+	Replacements []string
+
+	// normalize 为 true 时生成的链接还会经过 url.Parse 的规范化，只用于 DefaultEscapePolicy
+	normalize bool
+}
+
+var (
+	// DefaultEscapePolicy 默认的规则，与 MakePublicURLv2、MakePrivateURLv2 一致：按照 KeepPipeEscapePolicy 的规则 escape 之后，
+	// 链接还会经过 url.Parse 的规范化，如文件名包含 '|' 时，路径会被重新 escape，'|' 变为 %7C，'+' 和 ':' 则不会被 escape
+	DefaultEscapePolicy = &EscapePolicy{Replacements: []string{"%2F", "/", "%7C", "|", "+", "%20"}, normalize: true}
+
+	// KeepPipeEscapePolicy 保留 '/' 和 '|'，空格 escape 为 %20，'+' escape 为 %2B，':' escape 为 %3A，生成的链接不会被重新 escape
+	KeepPipeEscapePolicy = &EscapePolicy{Replacements: []string{"%2F", "/", "%7C", "|", "+", "%20"}}
+
+	// PlusSpaceEscapePolicy 与 KeepPipeEscapePolicy 相同，但空格 escape 为 '+'，适用于按照表单编码解码路径的 CDN
+	PlusSpaceEscapePolicy = &EscapePolicy{Replacements: []string{"%2F", "/", "%7C", "|"}}
+
+	// KeepColonEscapePolicy 与 KeepPipeEscapePolicy 相同，但保留 ':'，适用于不会解码 %3A 的 CDN
+	KeepColonEscapePolicy = &EscapePolicy{Replacements: []string{"%2F", "/", "%7C", "|", "%3A", ":", "+", "%20"}}
+
+	// StrictEscapePolicy 只保留 '/'，其他保留字符都会被 escape，与 RFC 3986 对路径的要求一致
+	StrictEscapePolicy = &EscapePolicy{Replacements: []string{"%2F", "/", "+", "%20"}}
+)
+
+// escape 按照规则 escape 文件名，p 为 nil 时使用 DefaultEscapePolicy
+func (p *EscapePolicy) escape(key string) string {
+	if p == nil {
+		p = DefaultEscapePolicy
+	}
+	return strings.NewReplacer(p.Replacements...).Replace(url.QueryEscape(key))
+}
+
+// MakePublicURLWithPolicy 与 MakePublicURLv2 相同，但按照 policy 对文件名进行 escape，
+// policy 为 nil 或者 DefaultEscapePolicy 时与 MakePublicURLv2 完全一致
+func MakePublicURLWithPolicy(domain, key string, policy *EscapePolicy) string {
+	return makePublicURL(domain, key, "", policy)
+}
+
+// MakePrivateURLWithPolicy 与 MakePrivateURLv2 相同，但按照 policy 对文件名进行 escape，签名覆盖 escape 之后的链接，
+// policy 为 nil 或者 DefaultEscapePolicy 时与 MakePrivateURLv2 完全一致
+func MakePrivateURLWithPolicy(mac *auth.Credentials, domain, key string, policy *EscapePolicy, deadline int64) string {
+	return makePrivateURL(mac, domain, key, "", deadline, policy)
+}
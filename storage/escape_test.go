@@ -0,0 +1,48 @@
+// +build unit
+
+package storage
+
+import (
+	"testing"
+
+	"github.com/qiniu/go-sdk/v7/auth"
+)
+
+func TestEscapePolicy(t *testing.T) {
+	const key = "dir/a b+c:d|e.jpg"
+	for _, c := range []struct {
+		policy *EscapePolicy
+		want   string
+	}{
+		{nil, "dir/a%20b%2Bc%3Ad|e.jpg"},
+		{DefaultEscapePolicy, "dir/a%20b%2Bc%3Ad|e.jpg"},
+		{KeepPipeEscapePolicy, "dir/a%20b%2Bc%3Ad|e.jpg"},
+		{PlusSpaceEscapePolicy, "dir/a+b%2Bc%3Ad|e.jpg"},
+		{KeepColonEscapePolicy, "dir/a%20b%2Bc:d|e.jpg"},
+		{StrictEscapePolicy, "dir/a%20b%2Bc%3Ad%7Ce.jpg"},
+	} {
+		if got := c.policy.escape(key); got != c.want {
+			t.Errorf("policy %v: want %s, got %s", c.policy, c.want, got)
+		}
+	}
+
+	mac := auth.New("ak", "sk")
+	for _, policy := range []*EscapePolicy{nil, DefaultEscapePolicy} {
+		if got, want := MakePrivateURLWithPolicy(mac, "http://example.com", key, policy, 100), MakePrivateURLv2(mac, "http://example.com", key, 100); got != want {
+			t.Fatalf("policy %v: want %s, got %s", policy, want, got)
+		}
+		if got, want := MakePublicURLWithPolicy("http://example.com", key, policy), "http://example.com/dir/a%20b+c:d%7Ce.jpg"; got != want {
+			t.Fatalf("policy %v: want %s, got %s", policy, want, got)
+		}
+	}
+	if got, want := MakePublicURLWithPolicy("http://example.com", key, KeepPipeEscapePolicy), "http://example.com/dir/a%20b%2Bc%3Ad|e.jpg"; got != want {
+		t.Fatalf("want %s, got %s", want, got)
+	}
+	urlToSign := "http://example.com/dir/a%20b%2Bc:d|e.jpg?e=100"
+	if got, want := MakePrivateURLWithPolicy(mac, "http://example.com/", key, KeepColonEscapePolicy, 100), urlToSign+"&token="+mac.Sign([]byte(urlToSign)); got != want {
+		t.Fatalf("want %s, got %s", want, got)
+	}
+	if got, want := MakePublicURLWithPolicy("http://example.com", key, PlusSpaceEscapePolicy), "http://example.com/dir/a+b%2Bc%3Ad|e.jpg"; got != want {
+		t.Fatalf("want %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ExportFormat 导出文件列表的格式
+type ExportFormat int
+
+const (
+	// ExportCSV 逗号分隔
+	ExportCSV ExportFormat = iota
+	// ExportTSV 制表符分隔
+	ExportTSV
+)
+
+// ExportColumn 导出文件列表的列
+type ExportColumn string
+
+// 可以导出的列，列名即为第一行中的名称
+const (
+	ExportColumnKey         ExportColumn = "key"
+	ExportColumnFsize       ExportColumn = "fsize"
+	ExportColumnPutTime     ExportColumn = "putTime" // RFC3339 格式的 UTC 时间
+	ExportColumnMimeType    ExportColumn = "mimeType"
+	ExportColumnStorageType ExportColumn = "storageType" // 存储类型的名称，参见 StorageType.String
+	ExportColumnHash        ExportColumn = "hash"
+	ExportColumnEndUser     ExportColumn = "endUser"
+)
+
+// DefaultExportColumns ExportListing 导出的列
+var DefaultExportColumns = []ExportColumn{
+	ExportColumnKey, ExportColumnFsize, ExportColumnPutTime, ExportColumnMimeType, ExportColumnStorageType,
+}
+
+// ExportListing 流式列举空间中以 prefix 开头的文件，并以 format 格式将 DefaultExportColumns 中的列写入 w，第一行为列名。
+// 每列举到一个文件就写入一行，不会在内存中保存整个文件列表
+func (m *BucketManager) ExportListing(ctx context.Context, bucket, prefix string, w io.Writer, format ExportFormat) error {
+	return m.ExportListingColumns(ctx, bucket, prefix, w, format, DefaultExportColumns)
+}
+
+// ExportListingColumns 与 ExportListing 相同，可以通过 columns 指定导出的列及其顺序
+func (m *BucketManager) ExportListingColumns(ctx context.Context, bucket, prefix string, w io.Writer, format ExportFormat, columns []ExportColumn) (err error) {
+	if len(columns) == 0 {
+		return errors.New("columns is empty")
+	}
+	cw := csv.NewWriter(w)
+	switch format {
+	case ExportCSV:
+	case ExportTSV:
+		cw.Comma = '\t'
+	default:
+		return fmt.Errorf("unknown export format: %d", format)
+	}
+	row := make([]string, len(columns))
+	for i, column := range columns {
+		if _, err = exportValue(column, &ListItem{}); err != nil {
+			return
+		}
+		row[i] = string(column)
+	}
+	if err = cw.Write(row); err != nil {
+		return
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	err = m.listStream(ctx, bucket, prefix, "", "", func(ret *listFilesRet2) error {
+		if ret.Dir != "" || ret.Item.Key == "" {
+			return nil
+		}
+		for i, column := range columns {
+			row[i], _ = exportValue(column, &ret.Item)
+		}
+		return cw.Write(row)
+	})
+	cw.Flush()
+	if err == nil {
+		err = cw.Error()
+	}
+	return
+}
+
+// exportValue 返回文件在指定列的值
+func exportValue(column ExportColumn, item *ListItem) (value string, err error) {
+	switch column {
+	case ExportColumnKey:
+		value = item.Key
+	case ExportColumnFsize:
+		value = strconv.FormatInt(item.Fsize, 10)
+	case ExportColumnPutTime:
+		// PutTime 的单位为 100 纳秒
+		value = time.Unix(0, item.PutTime*100).UTC().Format(time.RFC3339)
+	case ExportColumnMimeType:
+		value = item.MimeType
+	case ExportColumnStorageType:
+		value = StorageType(item.Type).String()
+	case ExportColumnHash:
+		value = item.Hash
+	case ExportColumnEndUser:
+		value = item.EndUser
+	default:
+		err = fmt.Errorf("unknown export column: %q", column)
+	}
+	return
+}
@@ -0,0 +1,46 @@
+// +build unit
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestExportListing(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/list" || r.URL.Query().Get("prefix") != "logs/" {
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"marker":"m1","item":{"key":"logs/a,b.txt","fsize":10,"putTime":16094592000000000,"mimeType":"text/plain","type":1}}` + "\n" +
+			`{"marker":"m2","dir":"logs/sub/"}` + "\n" +
+			`{"marker":"m3","item":{"key":"logs/c","fsize":0,"putTime":16094592010000000,"mimeType":"application/octet-stream","type":2,"hash":"h"}}` + "\n"))
+	})
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	if err := m.ExportListing(context.Background(), "bucket", "logs/", &buf, ExportCSV); err != nil {
+		t.Fatal(err)
+	}
+	want := "key,fsize,putTime,mimeType,storageType\n" +
+		"\"logs/a,b.txt\",10,2021-01-01T00:00:00Z,text/plain,IA\n" +
+		"logs/c,0,2021-01-01T00:00:01Z,application/octet-stream,ARCHIVE\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected csv:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := m.ExportListingColumns(context.Background(), "bucket", "logs/", &buf, ExportTSV, []ExportColumn{ExportColumnHash, ExportColumnKey}); err != nil {
+		t.Fatal(err)
+	}
+	if want = "hash\tkey\n\tlogs/a,b.txt\nh\tlogs/c\n"; buf.String() != want {
+		t.Fatalf("unexpected tsv: %q", buf.String())
+	}
+
+	if err := m.ExportListingColumns(context.Background(), "bucket", "logs/", &buf, ExportCSV, []ExportColumn{"size"}); err == nil {
+		t.Fatal("expect error for unknown column")
+	}
+}
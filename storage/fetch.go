@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ResolveFetchURL 在本地跟随 resURL 的重定向，返回最终的链接，重定向次数超过 maxRedirects 时返回 ErrTooManyRedirects，
+// maxRedirects 为 0 时不允许重定向。抓取在七牛服务端进行，客户端无法控制服务端是否跟随重定向，
+// 因此可以先通过该方法解析出最终的链接并检查其域名，再使用 FetchResolved 抓取最终的链接。
+// 解析时优先使用 HEAD 请求，源站不支持 HEAD 时使用 GET 请求，但不会读取响应内容
+func ResolveFetchURL(ctx context.Context, resURL string, maxRedirects int) (finalURL string, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	clt := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return ErrTooManyRedirects
+			}
+			return nil
+		},
+	}
+	for _, method := range []string{"HEAD", "GET"} {
+		req, rErr := http.NewRequest(method, resURL, nil)
+		if rErr != nil {
+			return "", rErr
+		}
+		resp, dErr := clt.Do(req.WithContext(ctx))
+		if dErr != nil {
+			if uErr, ok := dErr.(*url.Error); ok && uErr.Err == ErrTooManyRedirects {
+				return "", ErrTooManyRedirects
+			}
+			return "", dErr
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
+			continue
+		}
+		if resp.StatusCode/100 != 2 {
+			return "", fmt.Errorf("resolve %s: unexpected status %s", resURL, resp.Status)
+		}
+		return resp.Request.URL.String(), nil
+	}
+	return "", fmt.Errorf("resolve %s: neither HEAD nor GET is allowed", resURL)
+}
+
+// FetchResolved 使用 ResolveFetchURL 解析 resURL 的最终链接，allowHost 不为 nil 时检查最终链接的域名（包含端口），
+// 不允许时返回错误，否则从最终的链接抓取资源到空间中，服务端抓取时不会再经过 resURL 的重定向
+func (m *BucketManager) FetchResolved(ctx context.Context, resURL, bucket, key string, maxRedirects int, allowHost func(host string) bool) (fetchRet FetchRet, err error) {
+	finalURL, err := ResolveFetchURL(ctx, resURL, maxRedirects)
+	if err != nil {
+		return
+	}
+	if allowHost != nil {
+		u, pErr := url.Parse(finalURL)
+		if pErr != nil {
+			err = pErr
+			return
+		}
+		if !allowHost(u.Host) {
+			err = fmt.Errorf("fetch host %s is not allowed", u.Host)
+			return
+		}
+	}
+	return m.Fetch(finalURL, bucket, key)
+}
@@ -0,0 +1,58 @@
+// +build unit
+
+package storage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResolveFetchURL(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusFound)
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusFound)
+		case "/c":
+			if r.Method == "HEAD" {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer origin.Close()
+
+	finalURL, err := ResolveFetchURL(context.Background(), origin.URL+"/a", 2)
+	if err != nil || finalURL != origin.URL+"/c" {
+		t.Fatalf("finalURL = %s, err = %v", finalURL, err)
+	}
+	if _, err = ResolveFetchURL(context.Background(), origin.URL+"/a", 1); err != ErrTooManyRedirects {
+		t.Fatalf("want ErrTooManyRedirects, got %v", err)
+	}
+	if _, err = ResolveFetchURL(context.Background(), origin.URL+"/missing", 1); err == nil {
+		t.Fatal("expect error for missing resource")
+	}
+
+	var fetched string
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		fetched = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"hash":"h","key":"key"}`))
+	})
+	defer srv.Close()
+
+	if _, err = m.FetchResolved(context.Background(), origin.URL+"/a", "bucket", "key", 2, func(host string) bool {
+		return strings.HasPrefix(origin.URL, "http://"+host)
+	}); err != nil || fetched != uriFetch(origin.URL+"/c", "bucket", "key") {
+		t.Fatalf("fetched = %s, err = %v", fetched, err)
+	}
+	fetched = ""
+	if _, err = m.FetchResolved(context.Background(), origin.URL+"/a", "bucket", "key", 2, func(string) bool { return false }); err == nil || fetched != "" {
+		t.Fatalf("fetched = %s, err = %v", fetched, err)
+	}
+}
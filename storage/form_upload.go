@@ -3,6 +3,7 @@ package storage
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/qiniu/go-sdk/v7/internal/hostprovider"
 	"hash"
@@ -18,6 +19,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/qiniu/go-sdk/v7/auth"
 	"github.com/qiniu/go-sdk/v7/client"
 )
 
@@ -409,6 +411,28 @@ func writeMultipart(writer *multipart.Writer, uptoken, key string, hasKey bool,
 	return err
 }
 
+// BuildUploadForm 生成浏览器直传所需的表单上传地址和表单字段，字段包含 token 以及非空时的 key，
+// 浏览器需要在此基础上追加 file 字段后以 multipart/form-data 的方式 POST 到 upHost，上传地址使用 HTTPS
+func BuildUploadForm(mac *auth.Credentials, policy *PutPolicy, key string) (upHost string, fields map[string]string, err error) {
+	return buildUploadForm(&Config{UseHTTPS: true}, mac, policy, key)
+}
+
+func buildUploadForm(cfg *Config, mac *auth.Credentials, policy *PutPolicy, key string) (upHost string, fields map[string]string, err error) {
+	if policy == nil || policy.Scope == "" {
+		err = errors.New("put policy scope is empty")
+		return
+	}
+	bucket := strings.Split(policy.Scope, ":")[0]
+	if upHost, err = getUpHost(cfg, mac.AccessKey, bucket); err != nil {
+		return
+	}
+	fields = map[string]string{"token": policy.UploadToken(mac)}
+	if key != "" {
+		fields["key"] = key
+	}
+	return
+}
+
 var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
 
 func escapeQuotes(s string) string {
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// lifecycleBatch 一批需要发送的批量操作，keys[i] 为 ops[i] 对应的文件名
+type lifecycleBatch struct {
+	ops  []string
+	keys []string
+}
+
+// ApplyLifecycleToExisting 将生命周期规则 rule 应用到空间中已经存在的、以 rule.Prefix 开头的文件上，返回被修改的文件数量
+//
+// 服务端的生命周期规则默认只对规则创建后上传的文件生效，该方法在本地列举文件，对上传时间已经超过转换天数的文件
+// 调用 chtype 修改为规则中最冷的存储类型（只会修改为更冷的存储类型），并在 rule.DeleteAfterDays > 0 时调用 deleteAfterDays 设置删除时间。
+// 操作通过 Batch 接口以 concurrency 个并发发送，concurrency <= 0 时为 1，ctx 取消时正在发送的请求也会被取消。
+// 规则中小于 0 的天数表示新上传的文件立即转换，不适用于已经存在的文件，此时返回错误。
+//
+// 这是尽力而为的批量操作，与服务端的规则相互独立：单个文件的操作失败不会中断处理，也不会计入修改数量；
+// 列举或批量请求失败时返回遇到的第一个错误，此时已经完成的修改不会回滚
+func (m *BucketManager) ApplyLifecycleToExisting(ctx context.Context, bucket string, rule BucketLifeCycleRule, concurrency int) (affected int, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if rule.ToLineAfterDays < 0 || rule.ToArchiveAfterDays < 0 || rule.ToDeepArchiveAfterDays < 0 || rule.DeleteAfterDays < 0 {
+		return 0, errors.New("lifecycle rule with negative days can not be applied to existing files")
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		batches  = make(chan lifecycleBatch)
+	)
+	setErr := func(e error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = e
+		}
+		mu.Unlock()
+	}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				rets, bErr := m.batchChunk(ctx, batch.ops)
+				if bErr != nil {
+					setErr(bErr)
+					continue
+				}
+				modified := make(map[string]struct{})
+				for j, ret := range rets {
+					if ret.Code/100 == 2 {
+						modified[batch.keys[j]] = struct{}{}
+					}
+				}
+				mu.Lock()
+				affected += len(modified)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	now := time.Now()
+	batch := lifecycleBatch{}
+	send := func() bool {
+		if len(batch.ops) == 0 {
+			return true
+		}
+		select {
+		case batches <- batch:
+			batch = lifecycleBatch{}
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	walkErr := m.WalkPrefix(ctx, bucket, rule.Prefix, nil, func(item ListItem) error {
+		ops := lifecycleOps(bucket, item, &rule, now)
+		// 同一个文件的操作放在同一批中，保证修改数量的统计准确
+		if len(batch.ops)+len(ops) > batchLimit && !send() {
+			return ctx.Err()
+		}
+		for _, op := range ops {
+			batch.ops = append(batch.ops, op)
+			batch.keys = append(batch.keys, item.Key)
+		}
+		return nil
+	})
+	if walkErr == nil && !send() {
+		walkErr = ctx.Err()
+	}
+	close(batches)
+	wg.Wait()
+
+	if walkErr != nil {
+		err = walkErr
+	} else {
+		err = firstErr
+	}
+	return
+}
+
+// lifecycleOps 返回将 item 与 rule 保持一致所需的操作
+func lifecycleOps(bucket string, item ListItem, rule *BucketLifeCycleRule, now time.Time) (ops []string) {
+	age := int(now.Sub(ParsePutTime(item.PutTime)) / (24 * time.Hour))
+	reached := func(days int) bool {
+		return days > 0 && age >= days
+	}
+
+	target := StorageType(item.Type)
+	switch {
+	case reached(rule.ToDeepArchiveAfterDays):
+		target = StorageTypeDeepArchive
+	case reached(rule.ToArchiveAfterDays):
+		target = StorageTypeArchive
+	case reached(rule.ToLineAfterDays):
+		target = StorageTypeIA
+	}
+	if target > StorageType(item.Type) {
+		ops = append(ops, URIChangeType(bucket, item.Key, int(target)))
+	}
+	if rule.DeleteAfterDays > 0 {
+		ops = append(ops, URIDeleteAfterDays(bucket, item.Key, rule.DeleteAfterDays))
+	}
+	return
+}
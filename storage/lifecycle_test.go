@@ -0,0 +1,113 @@
+// +build unit
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestApplyLifecycleToExisting(t *testing.T) {
+	putTime := func(daysAgo int) int64 {
+		return time.Now().Add(-time.Duration(daysAgo)*24*time.Hour).UnixNano() / 100
+	}
+	var (
+		mu  sync.Mutex
+		ops []string
+	)
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/list":
+			if r.URL.Query().Get("prefix") != "logs/" {
+				t.Errorf("unexpected prefix: %s", r.URL.Query().Get("prefix"))
+			}
+			fmt.Fprintf(w, `{"items":[
+				{"key":"logs/new","putTime":%d,"type":0},
+				{"key":"logs/old","putTime":%d,"type":0},
+				{"key":"logs/older","putTime":%d,"type":0},
+				{"key":"logs/archived","putTime":%d,"type":2}
+			]}`, putTime(1), putTime(40), putTime(100), putTime(100))
+		case "/batch":
+			r.ParseForm()
+			mu.Lock()
+			ops = append(ops, r.PostForm["op"]...)
+			mu.Unlock()
+			rets := make([]string, 0, len(r.PostForm["op"]))
+			for _, op := range r.PostForm["op"] {
+				if strings.Contains(op, EncodedEntry("bucket", "logs/archived")) {
+					rets = append(rets, `{"code":400,"data":{"error":"invalid"}}`)
+				} else {
+					rets = append(rets, `{"code":200}`)
+				}
+			}
+			w.Write([]byte("[" + strings.Join(rets, ",") + "]"))
+		default:
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+	})
+	defer srv.Close()
+
+	rule := BucketLifeCycleRule{Prefix: "logs/", ToLineAfterDays: 30, ToArchiveAfterDays: 90}
+	affected, err := m.ApplyLifecycleToExisting(context.Background(), "bucket", rule, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if affected != 2 {
+		t.Fatalf("affected = %d, want 2", affected)
+	}
+	want := []string{
+		URIChangeType("bucket", "logs/old", int(StorageTypeIA)),
+		URIChangeType("bucket", "logs/older", int(StorageTypeArchive)),
+	}
+	sort.Strings(ops)
+	sort.Strings(want)
+	if strings.Join(ops, ",") != strings.Join(want, ",") {
+		t.Fatalf("want = %v, got = %v", want, ops)
+	}
+
+	ops = nil
+	rule.DeleteAfterDays = 365
+	if affected, err = m.ApplyLifecycleToExisting(context.Background(), "bucket", rule, 0); err != nil {
+		t.Fatal(err)
+	}
+	if affected != 3 || len(ops) != 6 {
+		t.Fatalf("affected = %d, ops = %v", affected, ops)
+	}
+
+	ops = nil
+	if _, err = m.ApplyLifecycleToExisting(context.Background(), "bucket", BucketLifeCycleRule{Prefix: "logs/", ToLineAfterDays: -1}, 1); err == nil || len(ops) != 0 {
+		t.Fatalf("negative days should be rejected, err = %v, ops = %v", err, ops)
+	}
+}
+
+func TestApplyLifecycleToExistingCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/list" {
+			fmt.Fprintf(w, `{"items":[{"key":"logs/old","putTime":%d,"type":0}]}`, time.Now().Add(-100*24*time.Hour).UnixNano()/100)
+			return
+		}
+		// 批量请求发送后取消，请求应该随 ctx 一起结束
+		cancel()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	defer srv.Close()
+
+	start := time.Now()
+	affected, err := m.ApplyLifecycleToExisting(ctx, "bucket", BucketLifeCycleRule{Prefix: "logs/", ToLineAfterDays: 30}, 1)
+	if err == nil || affected != 0 || time.Since(start) > 2*time.Second {
+		t.Fatalf("affected = %d, err = %v, elapsed = %s", affected, err, time.Since(start))
+	}
+}
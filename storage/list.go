@@ -0,0 +1,293 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qiniu/go-sdk/v7/auth"
+	"github.com/qiniu/go-sdk/v7/client"
+)
+
+// listResilientRetries ListBucketResilient 在没有任何进展的情况下连续重试的最大次数
+const listResilientRetries = 5
+
+// listRetryInterval ListBucketResilient 重新连接前等待的时间
+var listRetryInterval = time.Second
+
+// ListBucketResilient 与 ListBucketEntries 相同，流式返回空间文件列表，但连接中断或者服务端返回 5xx 错误时，
+// 会使用最后一个成功返回的 marker 重新发起列举并跳过重复的边界项，调用方不会感知到重连。
+// 无法恢复的错误（如空间不存在）或者连续重试失败时，通过 channel 返回 Err 不为 nil 的最后一项后关闭 channel
+func (m *BucketManager) ListBucketResilient(ctx context.Context, bucket, prefix, delimiter string) (retCh chan ListEntry, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if _, err = m.RsfReqHost(bucket); err != nil {
+		return
+	}
+
+	retCh = make(chan ListEntry)
+	go func() {
+		defer close(retCh)
+		var marker, lastName string
+		for failures := 0; ; {
+			progressed := false
+			lErr := m.listStream(ctx, bucket, prefix, delimiter, marker, func(ret *listFilesRet2) error {
+				name := ret.name()
+				if name == "" || name == lastName {
+					return nil
+				}
+				entry := ListEntry{Marker: ret.Marker, Dir: ret.Dir}
+				if ret.Dir == "" {
+					item := ret.Item
+					entry.Item = &item
+				}
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case retCh <- entry:
+				}
+				marker, lastName, progressed = ret.Marker, name, true
+				return nil
+			})
+			if lErr == nil || ctx.Err() != nil {
+				return
+			}
+			if progressed {
+				failures = 0
+			}
+			if failures++; failures > listResilientRetries || !listRetryable(lErr) {
+				select {
+				case <-ctx.Done():
+				case retCh <- ListEntry{Marker: marker, Err: lErr}:
+				}
+				return
+			}
+
+			timer := time.NewTimer(listRetryInterval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+	}()
+	return
+}
+
+// listRetryable 判断列举失败后是否可以重试，网络错误和 5xx 错误可以重试
+func listRetryable(err error) bool {
+	if errInfo, ok := err.(*ErrorInfo); ok {
+		return errInfo.Code/100 == 5
+	}
+	return !IsNoSuchBucket(err)
+}
+
+// listStream 发起一次流式列举，并对每一项调用 fn，fn 返回错误时停止列举。
+// 与 callRetChan 不同，解析响应时的错误会被返回，而不是当作列举结束
+func (m *BucketManager) listStream(ctx context.Context, bucket, prefix, delimiter, marker string, fn func(ret *listFilesRet2) error) (err error) {
+	reqHost, err := m.RsfReqHost(bucket)
+	if err != nil {
+		return
+	}
+	ctx = auth.WithCredentialsType(ctx, m.Mac, auth.TokenQiniu)
+	reqURL := fmt.Sprintf("%s%s", reqHost, uriListFiles2(bucket, prefix, delimiter, marker))
+	resp, err := m.Client.DoRequestWith(ctx, "POST", reqURL, nil, nil, 0)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return client.ResponseError(resp)
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	if m.Cfg.UseJSONNumber {
+		dec.UseNumber()
+	}
+	for {
+		var ret listFilesRet2
+		if err = dec.Decode(&ret); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return
+		}
+		if err = fn(&ret); err != nil {
+			return
+		}
+	}
+}
+
+// commonPrefix 返回 a 和 b 的最长公共前缀
+func commonPrefix(a, b string) string {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return a[:n]
+}
+
+// ListRange 按照文件名的顺序流式返回空间中 [startKey, endKey) 范围内的文件，startKey 为空时从头开始，endKey 为空时不限制结束位置，
+// 可以将空间按照文件名划分为多个范围并行处理。列举接口不支持指定开始的文件名，因此会从 startKey 和 endKey 的公共前缀开始列举，
+// 在客户端跳过小于 startKey 的文件，并在遇到不小于 endKey 的文件时停止列举；endKey 为空时需要从空间的第一个文件开始列举。
+// 列举基于 ListBucketResilient，连接中断时会自动重连，无法继续列举时最后一项的 Err 不为空
+func (m *BucketManager) ListRange(ctx context.Context, bucket, startKey, endKey string) (retCh chan ListEntry, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if startKey != "" && endKey != "" && startKey >= endKey {
+		err = fmt.Errorf("startKey %q must be less than endKey %q", startKey, endKey)
+		return
+	}
+	prefix := ""
+	if endKey != "" {
+		prefix = commonPrefix(startKey, endKey)
+	}
+
+	listCtx, cancel := context.WithCancel(ctx)
+	srcCh, err := m.ListBucketResilient(listCtx, bucket, prefix, "")
+	if err != nil {
+		cancel()
+		return
+	}
+
+	retCh = make(chan ListEntry)
+	go func() {
+		defer close(retCh)
+		defer cancel()
+		for entry := range srcCh {
+			if entry.Err == nil {
+				if entry.Item == nil || entry.Item.Key < startKey {
+					continue
+				}
+				if endKey != "" && entry.Item.Key >= endKey {
+					return
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case retCh <- entry:
+			}
+		}
+	}()
+	return
+}
+
+// listPrefixesConcurrency ListPrefixes 同时列举的前缀数量
+const listPrefixesConcurrency = 4
+
+// ListPrefixes 列举空间中以 prefixes 中每个前缀开头的文件，返回前缀到文件列表的映射，delimiter 不为空时只返回该层的文件，不返回目录。
+// 多个前缀会并行列举，最多同时列举 4 个，重复的前缀只列举一次；任意一个前缀列举失败或者 ctx 取消时，停止所有列举并返回错误
+func (m *BucketManager) ListPrefixes(ctx context.Context, bucket string, prefixes []string, delimiter string) (items map[string][]ListItem, err error) {
+	items = make(map[string][]ListItem, len(prefixes))
+	unique := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		if _, ok := items[prefix]; !ok {
+			items[prefix] = []ListItem{}
+			unique = append(unique, prefix)
+		}
+	}
+
+	var mu sync.Mutex
+	err = forEachPrefix(ctx, unique, listPrefixesConcurrency, func(ctx context.Context, prefix string) error {
+		var list []ListItem
+		lErr := m.listStream(ctx, bucket, prefix, delimiter, "", func(ret *listFilesRet2) error {
+			if ret.Dir == "" && ret.Item.Key != "" {
+				list = append(list, ret.Item)
+			}
+			return nil
+		})
+		if lErr == nil && list != nil {
+			mu.Lock()
+			items[prefix] = list
+			mu.Unlock()
+		}
+		return lErr
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// ListAllSharded 并行列举空间中以 shardPrefixes 中每个前缀开头的文件，最多同时列举 concurrency 个前缀，并对每个文件调用 fn。
+// 调用方根据文件名的分布提供分片前缀，如十六进制开头的文件名可以使用 "0" 到 "f"，分片前缀不能重复或者互相包含，否则文件会被重复处理。
+// fn 会被多个 goroutine 同时调用，需要自行保证并发安全；fn 返回错误、任意一个分片列举失败或者 ctx 取消时，停止所有列举并返回错误，
+// fn 返回 SkipRemaining 时只停止当前分片的列举
+func (m *BucketManager) ListAllSharded(ctx context.Context, bucket string, shardPrefixes []string, concurrency int, fn func(ListItem) error) error {
+	if len(shardPrefixes) == 0 {
+		return errors.New("shardPrefixes is empty")
+	}
+	if fn == nil {
+		return errors.New("fn is nil")
+	}
+	for i, a := range shardPrefixes {
+		for j, b := range shardPrefixes {
+			if i != j && strings.HasPrefix(b, a) {
+				return fmt.Errorf("shard prefix %q overlaps with %q", b, a)
+			}
+		}
+	}
+	return forEachPrefix(ctx, shardPrefixes, concurrency, func(ctx context.Context, prefix string) error {
+		return m.WalkPrefix(ctx, bucket, prefix, nil, fn)
+	})
+}
+
+// forEachPrefix 使用最多 concurrency 个 goroutine 对每个前缀调用 fn，任意一次调用返回错误时取消 ctx 并返回第一个错误
+func forEachPrefix(ctx context.Context, prefixes []string, concurrency int, fn func(ctx context.Context, prefix string) error) (err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	if concurrency > len(prefixes) {
+		concurrency = len(prefixes)
+	}
+	var (
+		wg      sync.WaitGroup
+		once    sync.Once
+		prefixC = make(chan string)
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for prefix := range prefixC {
+				if fErr := fn(ctx, prefix); fErr != nil {
+					once.Do(func() {
+						err = fErr
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+send:
+	for _, prefix := range prefixes {
+		select {
+		case prefixC <- prefix:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(prefixC)
+	wg.Wait()
+
+	if err == nil {
+		err = ctx.Err()
+	}
+	return
+}
@@ -0,0 +1,45 @@
+package storage
+
+import "sort"
+
+// DiffListings 按照文件名比较两次列举的结果，返回新增、删除和修改（文件名相同但 Hash 不同）的文件，
+// added 和 changed 中为 new 中的文件，removed 中为 old 中的文件，结果均按照文件名排序。
+// 文件名为空的项会被忽略，同一次列举中文件名重复时以最后出现的一项为准
+func DiffListings(old, new []ListItem) (added, removed, changed []ListItem) {
+	oldItems := listingIndex(old)
+	newItems := listingIndex(new)
+
+	for key, item := range newItems {
+		oldItem, ok := oldItems[key]
+		if !ok {
+			added = append(added, item)
+		} else if oldItem.Hash != item.Hash {
+			changed = append(changed, item)
+		}
+	}
+	for key, item := range oldItems {
+		if _, ok := newItems[key]; !ok {
+			removed = append(removed, item)
+		}
+	}
+	sortListItems(added)
+	sortListItems(removed)
+	sortListItems(changed)
+	return
+}
+
+func listingIndex(items []ListItem) map[string]ListItem {
+	index := make(map[string]ListItem, len(items))
+	for _, item := range items {
+		if item.Key != "" {
+			index[item.Key] = item
+		}
+	}
+	return index
+}
+
+func sortListItems(items []ListItem) {
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Key < items[j].Key
+	})
+}
@@ -0,0 +1,41 @@
+// +build unit
+
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffListings(t *testing.T) {
+	old := []ListItem{
+		{Key: "a", Hash: "ha"},
+		{Key: "b", Hash: "hb"},
+		{Key: "c", Hash: "hc0"},
+		{Key: "c", Hash: "hc"},
+		{},
+	}
+	new := []ListItem{
+		{Key: "d", Hash: "hd"},
+		{Key: "c", Hash: "hc"},
+		{Key: "a", Hash: "ha2"},
+		{Key: "e", Hash: "he"},
+		{Key: ""},
+	}
+	added, removed, changed := DiffListings(old, new)
+
+	keys := func(items []ListItem) string {
+		var ks []string
+		for _, item := range items {
+			ks = append(ks, item.Key+":"+item.Hash)
+		}
+		return strings.Join(ks, ",")
+	}
+	if keys(added) != "d:hd,e:he" || keys(removed) != "b:hb" || keys(changed) != "a:ha2" {
+		t.Fatalf("added = %s, removed = %s, changed = %s", keys(added), keys(removed), keys(changed))
+	}
+
+	if added, removed, changed = DiffListings(nil, nil); added != nil || removed != nil || changed != nil {
+		t.Fatal("diff of empty listings should be empty")
+	}
+}
@@ -0,0 +1,341 @@
+// +build unit
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestListBucketResilient(t *testing.T) {
+	listRetryInterval = time.Millisecond
+	defer func() { listRetryInterval = time.Second }()
+
+	var markers []string
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		markers = append(markers, r.URL.Query().Get("marker"))
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("bucket") == "missing" {
+			w.WriteHeader(631)
+			w.Write([]byte(`{"error":"no such bucket"}`))
+			return
+		}
+		switch len(markers) {
+		case 1:
+			// 第一次请求在返回两项后中断
+			w.Write([]byte(`{"marker":"m1","item":{"key":"a"}}` + "\n" + `{"marker":"m2","dir":"b/"}` + "\n" + `{"marker":"m3","item":{"ke`))
+		case 2:
+			w.WriteHeader(599)
+			w.Write([]byte(`{"error":"server error"}`))
+		default:
+			w.Write([]byte(`{"marker":"m2","dir":"b/"}` + "\n" + `{"marker":"m3","item":{"key":"c"}}` + "\n"))
+		}
+	})
+	defer srv.Close()
+
+	retCh, err := m.ListBucketResilient(context.Background(), "bucket", "", "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for entry := range retCh {
+		if entry.Err != nil {
+			t.Fatal(entry.Err)
+		}
+		if entry.IsDir() {
+			names = append(names, entry.Dir)
+		} else {
+			names = append(names, entry.Item.Key)
+		}
+	}
+	if strings.Join(names, ",") != "a,b/,c" || strings.Join(markers, ",") != ",m2,m2" {
+		t.Fatalf("names = %v, markers = %v", names, markers)
+	}
+
+	retCh, err = m.ListBucketResilient(context.Background(), "missing", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []ListEntry
+	for entry := range retCh {
+		entries = append(entries, entry)
+	}
+	if len(entries) != 1 || !IsNoSuchBucket(entries[0].Err) {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+}
+
+func TestListRange(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if q := r.URL.Query(); q.Get("prefix") != "img/" || q.Get("marker") != "" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		for _, key := range []string{"img/a", "img/b", "img/c", "img/d", "img/e"} {
+			w.Write([]byte(`{"marker":"m","item":{"key":"` + key + `"}}` + "\n"))
+		}
+	})
+	defer srv.Close()
+
+	retCh, err := m.ListRange(context.Background(), "bucket", "img/b", "img/e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for entry := range retCh {
+		if entry.Err != nil {
+			t.Fatal(entry.Err)
+		}
+		keys = append(keys, entry.Item.Key)
+	}
+	if strings.Join(keys, ",") != "img/b,img/c,img/d" {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+
+	if _, err = m.ListRange(context.Background(), "bucket", "e", "b"); err == nil {
+		t.Fatal("expect error for invalid range")
+	}
+	if commonPrefix("img/b", "img/e") != "img/" || commonPrefix("a", "") != "" {
+		t.Fatal("unexpected common prefix")
+	}
+}
+
+func TestListRangeError(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(ErrorCodeNoSuchBucket)
+		w.Write([]byte(`{"error":"no such bucket"}`))
+	})
+	defer srv.Close()
+
+	retCh, err := m.ListRange(context.Background(), "bucket", "a", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []ListEntry
+	for entry := range retCh {
+		entries = append(entries, entry)
+	}
+	if len(entries) != 1 || !IsNoSuchBucket(entries[0].Err) {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed = true
+	return nil
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestListBucketContextCancel(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		flusher := w.(http.Flusher)
+		for i := 0; ; i++ {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(time.Millisecond):
+			}
+			if _, err := fmt.Fprintf(w, `{"marker":"m%d","item":{"key":"k%d"}}`+"\n", i, i); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	})
+	defer srv.Close()
+
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	retCh, err := m.ListBucketContext(ctx, "bucket", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if ret := <-retCh; ret.Item.Key != fmt.Sprintf("k%d", i) {
+			t.Fatalf("unexpected item: %+v", ret)
+		}
+	}
+	// 取消后不再读取 retCh，后台 goroutine 以及连接相关的 goroutine 都应该退出
+	cancel()
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutine leaked: before = %d, now = %d", before, runtime.NumGoroutine())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	body := &closeRecorder{Reader: strings.NewReader(`{"error":"no such bucket"}`)}
+	m.Client.Client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 631,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       body,
+			Request:    req,
+		}, nil
+	})}
+	if _, err = m.ListBucketContext(context.Background(), "bucket", "", "", ""); err == nil || !body.closed {
+		t.Fatalf("err = %v, body closed = %v", err, body.closed)
+	}
+}
+
+func TestListPrefixes(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch prefix := r.URL.Query().Get("prefix"); prefix {
+		case "a/":
+			if r.URL.Query().Get("delimiter") != "/" {
+				t.Errorf("unexpected query: %s", r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"marker":"m1","item":{"key":"a/1"}}` + "\n" + `{"marker":"m2","dir":"a/sub/"}` + "\n" + `{"marker":"m3","item":{"key":"a/2"}}` + "\n"))
+		case "b/":
+			w.Write([]byte(`{"marker":"m1","item":{"key":"b/1"}}` + "\n"))
+		case "missing/":
+			w.WriteHeader(631)
+			w.Write([]byte(`{"error":"no such bucket"}`))
+		}
+	})
+	defer srv.Close()
+
+	items, err := m.ListPrefixes(context.Background(), "bucket", []string{"a/", "b/", "empty/", "a/"}, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 || len(items["a/"]) != 2 || items["a/"][1].Key != "a/2" || len(items["b/"]) != 1 || items["empty/"] == nil || len(items["empty/"]) != 0 {
+		t.Fatalf("unexpected items: %+v", items)
+	}
+
+	if _, err = m.ListPrefixes(context.Background(), "bucket", []string{"b/", "missing/"}, ""); !IsNoSuchBucket(err) {
+		t.Fatalf("want 631, got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = m.ListPrefixes(ctx, "bucket", []string{"a/"}, ""); err != context.Canceled {
+		t.Fatalf("want context.Canceled, got %v", err)
+	}
+}
+
+func TestListAllSharded(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		prefix := r.URL.Query().Get("prefix")
+		if prefix == "e" {
+			w.WriteHeader(631)
+			w.Write([]byte(`{"error":"no such bucket"}`))
+			return
+		}
+		fmt.Fprintf(w, `{"items":[{"key":"%s1"},{"key":"%s2"}]}`, prefix, prefix)
+	})
+	defer srv.Close()
+
+	var (
+		mu   sync.Mutex
+		keys []string
+	)
+	err := m.ListAllSharded(context.Background(), "bucket", []string{"a", "b", "c", "d"}, 2, func(item ListItem) error {
+		mu.Lock()
+		defer mu.Unlock()
+		keys = append(keys, item.Key)
+		return nil
+	})
+	sort.Strings(keys)
+	if err != nil || strings.Join(keys, ",") != "a1,a2,b1,b2,c1,c2,d1,d2" {
+		t.Fatalf("keys = %v, err = %v", keys, err)
+	}
+
+	if err = m.ListAllSharded(context.Background(), "bucket", []string{"a", "e"}, 2, func(ListItem) error { return nil }); !IsNoSuchBucket(err) {
+		t.Fatalf("want 631, got %v", err)
+	}
+	stop := errors.New("stop")
+	if err = m.ListAllSharded(context.Background(), "bucket", []string{"a", "b"}, 1, func(ListItem) error { return stop }); err != stop {
+		t.Fatalf("want stop, got %v", err)
+	}
+	if err = m.ListAllSharded(context.Background(), "bucket", []string{"a", "ab"}, 2, func(ListItem) error { return nil }); err == nil {
+		t.Fatal("expect error for overlapping shards")
+	}
+}
+
+func TestListInvalidMarker(t *testing.T) {
+	calls := 0
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(640)
+		w.Write([]byte(`{"error":"invalid marker"}`))
+	})
+	defer srv.Close()
+
+	_, _, _, _, err := m.ListFiles("bucket", "", "", "expired", 10)
+	if errInfo, ok := err.(*ErrorInfo); !ok || errInfo.Code != ErrorCodeInvalidMarker || !IsInvalidMarker(err) {
+		t.Fatalf("want *ErrorInfo with code 640, got %#v", err)
+	}
+	if _, err = m.ListBucketContext(context.Background(), "bucket", "", "", "expired"); !IsInvalidMarker(err) {
+		t.Fatalf("want 640, got %#v", err)
+	}
+
+	calls = 0
+	retCh, err := m.ListBucketResilient(context.Background(), "bucket", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []ListEntry
+	for entry := range retCh {
+		entries = append(entries, entry)
+	}
+	if len(entries) != 1 || !IsInvalidMarker(entries[0].Err) || calls != 1 {
+		t.Fatalf("entries = %+v, calls = %d", entries, calls)
+	}
+}
+
+func TestListBucketNeedParts(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("needparts") == "true" {
+			w.Write([]byte(`{"marker":"m1","item":{"key":"a","fsize":6,"parts":[4,2]}}` + "\n"))
+		} else {
+			w.Write([]byte(`{"marker":"m1","item":{"key":"a","fsize":6}}` + "\n"))
+		}
+	})
+	defer srv.Close()
+
+	for _, c := range []struct {
+		opts  *ListBucketOpts
+		parts string
+	}{
+		{nil, "[]"},
+		{&ListBucketOpts{}, "[]"},
+		{&ListBucketOpts{NeedParts: true}, "[4 2]"},
+	} {
+		retCh, err := m.ListBucketWithOpts(context.Background(), "bucket", "", "", "", c.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var items []ListItem
+		for ret := range retCh {
+			items = append(items, ret.Item)
+		}
+		if len(items) != 1 || fmt.Sprint(items[0].Parts) != c.parts {
+			t.Fatalf("opts = %+v: items = %+v", c.opts, items)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// BuildManifest 按照文件名的顺序列举空间中以 prefix 开头的所有文件，向 w 写入每个文件的清单行 "key\thash\tsize\n"，
+// 并返回所有清单行的 SHA256（十六进制）。清单的内容只与文件名、hash 和大小有关，
+// 比较两个空间或前缀的 rootHash 即可判断文件是否一致，不一致时再比较清单的内容。
+// 注意文件名中的制表符和换行符不会被转义
+func (m *BucketManager) BuildManifest(ctx context.Context, bucket, prefix string, w io.Writer) (rootHash string, err error) {
+	h := sha256.New()
+	mw := io.MultiWriter(w, h)
+	err = m.WalkPrefix(ctx, bucket, prefix, nil, func(item ListItem) error {
+		_, wErr := fmt.Fprintf(mw, "%s\t%s\t%d\n", item.Key, item.Hash, item.Fsize)
+		return wErr
+	})
+	if err != nil {
+		return
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
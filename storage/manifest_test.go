@@ -0,0 +1,37 @@
+// +build unit
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+)
+
+func TestBuildManifest(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("prefix") != "dir/" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"items":[{"key":"dir/a","hash":"ha","fsize":1},{"key":"dir/b","hash":"hb","fsize":22}]}`))
+	})
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	rootHash, err := m.BuildManifest(context.Background(), "bucket", "dir/", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "dir/a\tha\t1\ndir/b\thb\t22\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected manifest: %q", buf.String())
+	}
+	sum := sha256.Sum256([]byte(want))
+	if rootHash != hex.EncodeToString(sum[:]) {
+		t.Fatalf("unexpected root hash: %s", rootHash)
+	}
+}
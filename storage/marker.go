@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"strings"
+)
+
+// markerTokenVersion 列举位置令牌的格式版本
+const markerTokenVersion = "v1"
+
+// EncodeMarker 将列举接口返回的 marker 编码为可以交给外部用户的令牌，
+// 令牌带有版本号并使用 SecretKey 进行 HMAC 签名，可以通过 DecodeMarker 还原，空的 marker 返回空字符串
+func (m *BucketManager) EncodeMarker(marker string) (token string) {
+	if marker == "" {
+		return ""
+	}
+	payload := markerTokenVersion + "." + base64.RawURLEncoding.EncodeToString([]byte(marker))
+	return payload + "." + m.signMarker(payload)
+}
+
+// DecodeMarker 将 EncodeMarker 生成的令牌还原为 marker，令牌格式不正确或者签名校验失败时返回 ErrInvalidMarkerToken，
+// 空的令牌返回空的 marker，即从头开始列举
+func (m *BucketManager) DecodeMarker(token string) (marker string, err error) {
+	if token == "" {
+		return "", nil
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != markerTokenVersion {
+		return "", ErrInvalidMarkerToken
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(m.signMarker(payload))) {
+		return "", ErrInvalidMarkerToken
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrInvalidMarkerToken
+	}
+	return string(data), nil
+}
+
+func (m *BucketManager) signMarker(payload string) string {
+	h := hmac.New(sha1.New, m.Mac.SecretKey)
+	h.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
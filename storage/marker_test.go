@@ -0,0 +1,28 @@
+// +build unit
+
+package storage
+
+import (
+	"testing"
+
+	"github.com/qiniu/go-sdk/v7/auth"
+)
+
+func TestMarkerToken(t *testing.T) {
+	m := NewBucketManager(auth.New("ak", "sk"), nil)
+	token := m.EncodeMarker("eyJjIjowLCJrIjoiYSJ9")
+	marker, err := m.DecodeMarker(token)
+	if err != nil || marker != "eyJjIjowLCJrIjoiYSJ9" {
+		t.Fatalf("marker = %s, err = %v", marker, err)
+	}
+	if marker, err = m.DecodeMarker(""); err != nil || marker != "" {
+		t.Fatalf("marker = %s, err = %v", marker, err)
+	}
+
+	other := NewBucketManager(auth.New("ak", "other"), nil)
+	for _, invalid := range []string{"raw-marker", token + "x", "v2" + token[2:], other.EncodeMarker("eyJjIjowLCJrIjoiYSJ9")} {
+		if _, err = m.DecodeMarker(invalid); err != ErrInvalidMarkerToken {
+			t.Fatalf("token %q: want ErrInvalidMarkerToken, got %v", invalid, err)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"mime"
+	"path"
+	"strings"
+)
+
+// DefaultMimeType 无法根据扩展名确定 MimeType 时使用的默认值
+const DefaultMimeType = "application/octet-stream"
+
+// GuessMimeByKey 根据文件名的扩展名推断 MimeType
+// 优先使用 Config.MimeOverrides 中的配置，其次使用系统的 mime 表，都没有匹配时返回 application/octet-stream
+func (c *Config) GuessMimeByKey(key string) string {
+	ext := strings.ToLower(path.Ext(key))
+	if ext == "" {
+		return DefaultMimeType
+	}
+	if c != nil {
+		for overrideExt, mimeType := range c.MimeOverrides {
+			overrideExt = strings.ToLower(overrideExt)
+			if !strings.HasPrefix(overrideExt, ".") {
+				overrideExt = "." + overrideExt
+			}
+			if overrideExt == ext && mimeType != "" {
+				return mimeType
+			}
+		}
+	}
+	if mimeType := mime.TypeByExtension(ext); mimeType != "" {
+		return mimeType
+	}
+	return DefaultMimeType
+}
+
+// ChangeMimeByExt 根据文件名的扩展名推断 MimeType 并修改文件的 MimeType，返回修改后的 MimeType
+// 推断规则参见 Config.GuessMimeByKey
+func (m *BucketManager) ChangeMimeByExt(bucket, key string) (mimeType string, err error) {
+	mimeType = m.Cfg.GuessMimeByKey(key)
+	err = m.ChangeMime(bucket, key, mimeType)
+	return
+}
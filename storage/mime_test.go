@@ -0,0 +1,36 @@
+// +build unit
+
+package storage
+
+import (
+	"testing"
+)
+
+func TestGuessMimeByKey(t *testing.T) {
+	cfg := &Config{
+		MimeOverrides: map[string]string{
+			"glb":  "model/gltf-binary",
+			"qext": "application/x-qext",
+			".PNG": "image/x-custom-png",
+		},
+	}
+	cases := []struct {
+		cfg  *Config
+		key  string
+		want string
+	}{
+		{cfg: cfg, key: "models/a.glb", want: "model/gltf-binary"},
+		{cfg: cfg, key: "a.GLB", want: "model/gltf-binary"},
+		{cfg: cfg, key: "a.qext", want: "application/x-qext"},
+		{cfg: cfg, key: "a.png", want: "image/x-custom-png"},
+		{cfg: cfg, key: "a.html", want: "text/html; charset=utf-8"},
+		{cfg: cfg, key: "a.unknown-ext", want: DefaultMimeType},
+		{cfg: cfg, key: "noext", want: DefaultMimeType},
+		{cfg: nil, key: "a.qext", want: DefaultMimeType},
+	}
+	for _, c := range cases {
+		if got := c.cfg.GuessMimeByKey(c.key); got != c.want {
+			t.Errorf("key = %s, want = %s, got = %s", c.key, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyErrors 保存多个文件操作失败的错误，key 为文件名
+type KeyErrors map[string]error
+
+func (e KeyErrors) Error() string {
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	msgs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		msgs = append(msgs, fmt.Sprintf("%q: %s", key, e[key]))
+	}
+	return fmt.Sprintf("%d keys failed: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// MirrorConfig 空间的镜像源配置
+type MirrorConfig struct {
+	// SiteURL 镜像源地址，为空时表示没有镜像源
+	SiteURL string
+
+	// Host 回源时使用的 Host 头，为空时使用镜像源地址中的域名
+	Host string
+}
+
+// ReconcileMirror 将空间的镜像源设置为 source：source 为 nil 或者 SiteURL 为空时取消镜像源，否则设置镜像源以及回源 Host。
+// 无论空间当前的镜像源配置如何，调用结果都相同，可以重复调用
+func (m *BucketManager) ReconcileMirror(bucket string, source *MirrorConfig) (err error) {
+	switch {
+	case source == nil || source.SiteURL == "":
+		return m.UnsetImage(bucket)
+	case source.Host != "":
+		return m.SetImageWithHost(source.SiteURL, bucket, source.Host)
+	default:
+		return m.SetImage(source.SiteURL, bucket)
+	}
+}
+
+// tokenBucket 令牌桶限流器，每秒生成 rate 个令牌，最多累积 rate 个
+type tokenBucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate int) *tokenBucket {
+	return &tokenBucket{rate: float64(rate), tokens: float64(rate), last: time.Now()}
+}
+
+// wait 等待获取一个令牌，ctx 取消时返回 ctx.Err()
+func (b *tokenBucket) wait(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		now := time.Now()
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.rate {
+			b.tokens = b.rate
+		}
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			return nil
+		}
+
+		timer := time.NewTimer(time.Duration((1 - b.tokens) / b.rate * float64(time.Second)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// WarmMirror 对镜像空间中的文件逐个调用 Prefetch，从镜像源拉取最新内容，以避免首次访问时回源。
+// 请求频率通过令牌桶限制为每秒最多 rps 个，同时最多有 rps 个请求在执行，避免镜像源因请求过多而限流。
+// 部分文件失败时返回 KeyErrors，ctx 取消时停止发送新的请求，正在执行的请求也会被取消，等待这些请求返回后返回 ctx.Err()
+func (m *BucketManager) WarmMirror(ctx context.Context, bucket string, keys []string, rps int) (err error) {
+	if rps <= 0 {
+		return errors.New("rps must be greater than 0")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		keyErrs = make(KeyErrors)
+		limiter = newTokenBucket(rps)
+		keyCh   = make(chan string)
+	)
+	for i := 0; i < rps && i < len(keys); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range keyCh {
+				pErr := m.entryCall(ctx, HostCategoryIo, nil, uriPrefetch(bucket, key), BucketKey{bucket, key})
+				if pErr != nil {
+					mu.Lock()
+					keyErrs[key] = pErr
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, key := range keys {
+		if err = limiter.wait(ctx); err != nil {
+			break
+		}
+		keyCh <- key
+	}
+	close(keyCh)
+	wg.Wait()
+
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil && len(keyErrs) > 0 {
+		err = keyErrs
+	}
+	return
+}
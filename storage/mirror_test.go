@@ -0,0 +1,124 @@
+// +build unit
+
+package storage
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWarmMirror(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests int
+	)
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		if r.URL.Path == uriPrefetch("bucket", "missing") {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(404)
+			w.Write([]byte(`{"error":"resource not found"}`))
+		}
+	})
+	defer srv.Close()
+
+	keys := []string{"missing"}
+	for i := 0; i < 29; i++ {
+		keys = append(keys, "key")
+	}
+	start := time.Now()
+	err := m.WarmMirror(context.Background(), "bucket", keys, 20)
+	elapsed := time.Since(start)
+
+	keyErrs, ok := err.(KeyErrors)
+	if !ok || len(keyErrs) != 1 || keyErrs["missing"] == nil || !strings.Contains(err.Error(), `"missing"`) {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	mu.Lock()
+	if requests != 30 {
+		t.Fatalf("requests = %d", requests)
+	}
+	mu.Unlock()
+	// 前 20 个请求使用初始的令牌，剩余 10 个需要等待约 0.5 秒
+	if elapsed < 400*time.Millisecond {
+		t.Fatalf("rate limit not applied, elapsed %s", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err = m.WarmMirror(ctx, "bucket", keys, 1); err != context.Canceled {
+		t.Fatalf("want context.Canceled, got %v", err)
+	}
+	if err = m.WarmMirror(context.Background(), "bucket", keys, 0); err == nil {
+		t.Fatal("expect error for invalid rps")
+	}
+}
+
+func TestWarmMirrorConcurrency(t *testing.T) {
+	var (
+		mu                    sync.Mutex
+		inFlight, maxInFlight int
+	)
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if inFlight++; inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(600 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	})
+	defer srv.Close()
+
+	// 每 250 毫秒生成一个令牌，请求返回前已经可以发送更多的请求，但同时执行的请求不能超过 rps 个
+	if err := m.WarmMirror(context.Background(), "bucket", make([]string, 8), 4); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if maxInFlight != 4 {
+		t.Fatalf("maxInFlight = %d", maxInFlight)
+	}
+}
+
+func TestReconcileMirror(t *testing.T) {
+	m, srv := newMockBucketManager(nil)
+	srv.Close()
+
+	var paths []string
+	m.Client.Client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host != DefaultPubHost {
+			t.Errorf("unexpected host: %s", req.URL.Host)
+		}
+		paths = append(paths, req.URL.Path)
+		return &http.Response{StatusCode: 200, Header: http.Header{}, Body: http.NoBody, Request: req}, nil
+	})}
+
+	for _, source := range []*MirrorConfig{
+		{SiteURL: "http://example.com"},
+		{SiteURL: "http://example.com", Host: "origin.example.com"},
+		{},
+		nil,
+	} {
+		if err := m.ReconcileMirror("bucket", source); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{
+		uriSetImage("http://example.com", "bucket"),
+		uriSetImageWithHost("http://example.com", "bucket", "origin.example.com"),
+		uriUnsetImage("bucket"),
+		uriUnsetImage("bucket"),
+	}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Fatalf("want = %v, got = %v", want, paths)
+	}
+}
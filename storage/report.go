@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+)
+
+// StorageType 文件的存储类型
+type StorageType int
+
+const (
+	// StorageTypeStandard 标准存储
+	StorageTypeStandard StorageType = 0
+	// StorageTypeIA 低频存储
+	StorageTypeIA StorageType = 1
+	// StorageTypeArchive 归档存储
+	StorageTypeArchive StorageType = 2
+	// StorageTypeDeepArchive 深度归档存储
+	StorageTypeDeepArchive StorageType = 3
+)
+
+func (t StorageType) String() string {
+	switch t {
+	case StorageTypeStandard:
+		return "STANDARD"
+	case StorageTypeIA:
+		return "IA"
+	case StorageTypeArchive:
+		return "ARCHIVE"
+	case StorageTypeDeepArchive:
+		return "DEEP_ARCHIVE"
+	default:
+		return "StorageType(" + strconv.Itoa(int(t)) + ")"
+	}
+}
+
+// valid 判断是否为已知的存储类型
+func (t StorageType) valid() bool {
+	return t >= StorageTypeStandard && t <= StorageTypeDeepArchive
+}
+
+// GroupByStorageType 按照存储类型（FileInfo.Type）对文件信息进行分组，分组内保持原有的顺序
+func GroupByStorageType(infos []FileInfo) map[StorageType][]FileInfo {
+	groups := make(map[StorageType][]FileInfo)
+	for _, info := range infos {
+		t := StorageType(info.Type)
+		groups[t] = append(groups[t], info)
+	}
+	return groups
+}
+
+// bytesPerGB 计算存储费用时 1 GB 对应的字节数
+const bytesPerGB = 1 << 30
+
+// StorageReport 按照存储类型统计的文件数量和大小
+type StorageReport struct {
+	// 文件总数和总大小，单位：字节
+	TotalCount int64
+	TotalSize  int64
+
+	// 每种存储类型的文件数量和大小，单位：字节
+	Count map[StorageType]int64
+	Size  map[StorageType]int64
+}
+
+// Add 将一个文件计入统计
+func (r *StorageReport) Add(item ListItem) {
+	if r.Count == nil {
+		r.Count = make(map[StorageType]int64)
+	}
+	if r.Size == nil {
+		r.Size = make(map[StorageType]int64)
+	}
+	t := StorageType(item.Type)
+	r.TotalCount++
+	r.TotalSize += item.Fsize
+	r.Count[t]++
+	r.Size[t] += item.Fsize
+}
+
+// PricingTable 每种存储类型每 GB 每月的价格，由调用方根据实际的计费标准提供
+type PricingTable map[StorageType]float64
+
+// EstimateCost 根据每种存储类型每 GB 每月的价格 prices 估算每月的存储费用，
+// 返回总费用以及每种存储类型的费用，prices 中没有的存储类型不计算费用
+func (r *StorageReport) EstimateCost(prices map[StorageType]float64) (total float64, breakdown map[StorageType]float64) {
+	breakdown = make(map[StorageType]float64)
+	for t, size := range r.Size {
+		price, ok := prices[t]
+		if !ok {
+			continue
+		}
+		cost := float64(size) / bytesPerGB * price
+		breakdown[t] = cost
+		total += cost
+	}
+	return
+}
+
+// GetStorageReport 列举空间中以 prefix 开头的所有文件，并按照存储类型统计文件数量和大小
+func (m *BucketManager) GetStorageReport(ctx context.Context, bucket, prefix string) (report StorageReport, err error) {
+	err = m.WalkPrefix(ctx, bucket, prefix, nil, func(item ListItem) error {
+		report.Add(item)
+		return nil
+	})
+	return
+}
+
+// EstimateStorageCost 列举空间中以 prefix 开头的所有文件，根据每种存储类型每 GB 每月的价格 prices 估算每月的存储费用
+// 统计逻辑与 GetStorageReport 相同，费用的计算参见 StorageReport.EstimateCost
+func (m *BucketManager) EstimateStorageCost(ctx context.Context, bucket, prefix string, prices map[StorageType]float64) (total float64, breakdown map[StorageType]float64, err error) {
+	report, err := m.GetStorageReport(ctx, bucket, prefix)
+	if err != nil {
+		return
+	}
+	total, breakdown = report.EstimateCost(prices)
+	return
+}
+
+// EstimateMonthlyCost 列举空间中以 prefix 开头的所有文件，按照 pricing 中的价格估算每月的存储费用，
+// pricing 中没有的存储类型不计算费用，需要每种存储类型的费用时请使用 EstimateStorageCost
+func (m *BucketManager) EstimateMonthlyCost(ctx context.Context, bucket, prefix string, pricing PricingTable) (total float64, err error) {
+	total, _, err = m.EstimateStorageCost(ctx, bucket, prefix, pricing)
+	return
+}
+
+// StorageClassDistribution 返回空间中每种存储类型的文件总大小，单位：字节。
+// 注意该方法通过列举空间中的所有文件进行统计，文件较多时耗时较长，需要文件数量等更多信息时请使用 GetStorageReport
+func (m *BucketManager) StorageClassDistribution(bucket string) (sizes map[StorageType]int64, err error) {
+	report, err := m.GetStorageReport(context.Background(), bucket, "")
+	if err != nil {
+		return
+	}
+	sizes = report.Size
+	if sizes == nil {
+		sizes = make(map[StorageType]int64)
+	}
+	return
+}
@@ -0,0 +1,55 @@
+// +build unit
+
+package storage
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestEstimateStorageCost(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"items":[
+			{"key":"a","fsize":1073741824,"type":0},
+			{"key":"b","fsize":1073741824,"type":0},
+			{"key":"c","fsize":2147483648,"type":1},
+			{"key":"d","fsize":1073741824,"type":2}
+		]}`))
+	})
+	defer srv.Close()
+
+	report, err := m.GetStorageReport(context.Background(), "bucket", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.TotalCount != 4 || report.TotalSize != 5<<30 || report.Count[StorageTypeStandard] != 2 || report.Size[StorageTypeIA] != 2<<30 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	sizes, err := m.StorageClassDistribution("bucket")
+	if err != nil || len(sizes) != 3 || sizes[StorageTypeStandard] != 2<<30 || sizes[StorageTypeArchive] != 1<<30 {
+		t.Fatalf("sizes = %v, err = %v", sizes, err)
+	}
+
+	prices := map[StorageType]float64{
+		StorageTypeStandard: 0.25,
+		StorageTypeIA:       0.125,
+	}
+	total, breakdown, err := m.EstimateStorageCost(context.Background(), "bucket", "", prices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 0.75 || breakdown[StorageTypeStandard] != 0.5 || breakdown[StorageTypeIA] != 0.25 {
+		t.Fatalf("total = %v, breakdown = %v", total, breakdown)
+	}
+	if _, ok := breakdown[StorageTypeArchive]; ok {
+		t.Fatal("storage type without price should not be in breakdown")
+	}
+
+	total, err = m.EstimateMonthlyCost(context.Background(), "bucket", "", PricingTable{StorageTypeArchive: 0.5})
+	if err != nil || total != 0.5 {
+		t.Fatalf("total = %v, err = %v", total, err)
+	}
+}
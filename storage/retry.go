@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy 服务端限流（返回 429 或 503）时的重试策略，通过 Config.RetryPolicy 设置，为 nil 时不重试。
+// 响应带有 Retry-After 头时按照服务端指定的时间等待，否则按照 BaseDelay 开始的指数退避等待，等待时间都不超过 MaxDelay。
+// 覆盖目标文件的 Copy、CopyWithOpts（Force 为 true）以及 Fetch 是幂等的，这些操作遇到任意 5xx 错误都会重试，4xx 错误总是不会重试。
+// Move 会删除源文件，重复执行不是幂等的，因此不会在 5xx 时重试
+type RetryPolicy struct {
+	// MaxRetries 最大重试次数，为 0 时不重试
+	MaxRetries int
+
+	// BaseDelay 第一次重试前的等待时间，之后每次翻倍，为 0 时使用 1 秒
+	BaseDelay time.Duration
+
+	// MaxDelay 单次等待时间的上限，同时限制 Retry-After 指定的时间，为 0 时使用 30 秒
+	MaxDelay time.Duration
+
+	// OnRetry 每次等待重试前调用，可以用于记录日志或者统计，attempt 从 1 开始，delay 为实际等待的时间，
+	// retryAfter 为从 Retry-After 头解析出的时间，没有该头或者无法解析时为 0，err 为本次请求返回的错误
+	OnRetry func(attempt int, delay, retryAfter time.Duration, err error)
+}
+
+const (
+	defaultRetryBaseDelay = time.Second
+	defaultRetryMaxDelay  = 30 * time.Second
+)
+
+type idempotentKey struct{}
+
+// overwriteContext 返回发送 Copy、Fetch 等请求使用的 ctx，overwrite 为 true 时操作是幂等的，遇到 5xx 错误可以安全地重试
+func overwriteContext(overwrite bool) context.Context {
+	ctx := context.Background()
+	if overwrite {
+		ctx = context.WithValue(ctx, idempotentKey{}, true)
+	}
+	return ctx
+}
+
+// retryable 判断错误是否可以重试：服务端限流，或者幂等操作遇到 5xx 错误
+func (p *RetryPolicy) retryable(ctx context.Context, err error) bool {
+	e, ok := err.(*ErrorInfo)
+	if !ok {
+		return false
+	}
+	if e.Code == http.StatusTooManyRequests || e.Code == http.StatusServiceUnavailable {
+		return true
+	}
+	idempotent, _ := ctx.Value(idempotentKey{}).(bool)
+	return idempotent && e.Code/100 == 5
+}
+
+// delay 返回第 attempt 次重试前需要等待的时间，以及从 Retry-After 头解析出的时间
+func (p *RetryPolicy) delay(attempt int, err error) (delay, retryAfter time.Duration) {
+	maxDelay := p.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultRetryMaxDelay
+	}
+	if e, ok := err.(*ErrorInfo); ok {
+		if d, ok := parseRetryAfter(e.RetryAfter(), time.Now()); ok {
+			retryAfter = d
+			if d > maxDelay {
+				d = maxDelay
+			}
+			return d, retryAfter
+		}
+	}
+	delay = p.BaseDelay
+	if delay <= 0 {
+		delay = defaultRetryBaseDelay
+	}
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return
+}
+
+// parseRetryAfter 解析 Retry-After 头，支持秒数以及 HTTP 日期两种格式，已经过去的日期返回 0
+func parseRetryAfter(value string, now time.Time) (d time.Duration, ok bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if d = t.Sub(now); d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+// retry 按照 Config.RetryPolicy 执行 call，服务端限流时等待后重试，ctx 取消时返回 ctx.Err()
+func (m *BucketManager) retry(ctx context.Context, call func() error) (err error) {
+	policy := m.Cfg.RetryPolicy
+	for attempt := 1; ; attempt++ {
+		err = call()
+		if err == nil || policy == nil || attempt > policy.MaxRetries || !policy.retryable(ctx, err) {
+			return
+		}
+		delay, retryAfter := policy.delay(attempt, err)
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, delay, retryAfter, err)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
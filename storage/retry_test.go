@@ -0,0 +1,128 @@
+// +build unit
+
+package storage
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		value string
+		d     time.Duration
+		ok    bool
+	}{
+		{"3", 3 * time.Second, true},
+		{" 0 ", 0, true},
+		{"Fri, 01 Jan 2021 00:00:10 GMT", 10 * time.Second, true},
+		{"Thu, 31 Dec 2020 23:59:00 GMT", 0, true},
+		{"", 0, false},
+		{"-1", 0, false},
+		{"soon", 0, false},
+	}
+	for _, c := range cases {
+		d, ok := parseRetryAfter(c.value, now)
+		if d != c.d || ok != c.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", c.value, d, ok, c.d, c.ok)
+		}
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	calls := 0
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		switch calls {
+		case 1:
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error":"too many requests"}`))
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":"service unavailable"}`))
+		default:
+			w.Write([]byte(`{"hash":"h"}`))
+		}
+	})
+	defer srv.Close()
+
+	type retry struct {
+		attempt           int
+		delay, retryAfter time.Duration
+	}
+	var retries []retry
+	m.Cfg.RetryPolicy = &RetryPolicy{
+		MaxRetries: 2,
+		BaseDelay:  time.Millisecond,
+		MaxDelay:   5 * time.Millisecond,
+		OnRetry: func(attempt int, delay, retryAfter time.Duration, err error) {
+			retries = append(retries, retry{attempt, delay, retryAfter})
+		},
+	}
+	info, err := m.Stat("bucket", "key")
+	if err != nil || info.Hash != "h" || calls != 3 {
+		t.Fatalf("info = %+v, err = %v, calls = %d", info, err, calls)
+	}
+	if len(retries) != 2 || retries[0] != (retry{1, 5 * time.Millisecond, 120 * time.Second}) || retries[1] != (retry{2, 2 * time.Millisecond, 0}) {
+		t.Fatalf("unexpected retries: %+v", retries)
+	}
+
+	calls = 1
+	m.Cfg.RetryPolicy.MaxRetries = 0
+	if _, err = m.Stat("bucket", "key"); err == nil || err.(*ErrorInfo).Code != http.StatusServiceUnavailable {
+		t.Fatalf("want 503 without retry, got %v", err)
+	}
+
+	calls = 0
+	m.Cfg.RetryPolicy = &RetryPolicy{MaxRetries: 1, BaseDelay: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err = m.Do(ctx, "POST", HostCategoryRs, "bucket", "/stat/x", nil, nil); err != context.DeadlineExceeded {
+		t.Fatalf("want context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestRetryIdempotent5xx(t *testing.T) {
+	var codes []int
+	calls := 0
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		code := codes[calls]
+		calls++
+		w.WriteHeader(code)
+		w.Write([]byte(`{"error":"error"}`))
+	})
+	defer srv.Close()
+	m.Cfg.RetryPolicy = &RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond}
+
+	for _, c := range []struct {
+		codes []int
+		call  func() error
+		calls int
+		ok    bool
+	}{
+		{[]int{500, 200}, func() error { return m.Copy("src", "a", "dest", "b", true) }, 2, true},
+		{[]int{599, 502, 200}, func() error { return m.Copy("src", "a", "dest", "b", true) }, 3, true},
+		{[]int{502, 200}, func() error { return m.Move("src", "a", "dest", "b", true) }, 1, false},
+		{[]int{502, 500, 200}, func() error {
+			_, err := m.CopyWithOpts("src", "a", "dest", "b", &CopyOpts{Force: true})
+			return err
+		}, 3, true},
+		{[]int{500, 200}, func() error { _, err := m.CopyWithOpts("src", "a", "dest", "b", nil); return err }, 1, false},
+		{[]int{500, 200}, func() error { _, err := m.Fetch("http://example.com/a", "bucket", "a"); return err }, 2, true},
+		{[]int{500, 200}, func() error { return m.Copy("src", "a", "dest", "b", false) }, 1, false},
+		{[]int{500, 200}, func() error { return m.Delete("bucket", "a") }, 1, false},
+		{[]int{400, 200}, func() error { return m.Copy("src", "a", "dest", "b", true) }, 1, false},
+		{[]int{614, 200}, func() error { return m.Move("src", "a", "dest", "b", true) }, 1, false},
+	} {
+		codes, calls = c.codes, 0
+		if err := c.call(); (err == nil) != c.ok || calls != c.calls {
+			t.Fatalf("codes = %v: err = %v, calls = %d, want %d", c.codes, err, calls, c.calls)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package storage
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/qiniu/go-sdk/v7/auth"
+	"github.com/qiniu/go-sdk/v7/client"
+	"github.com/qiniu/go-sdk/v7/conf"
+)
+
+// SignRequest 为 req 设置管理凭证，与 SDK 内部发送请求时的签名方式相同：
+// 设置 X-Qiniu-Date 头（除非通过环境变量禁用了时间戳签名），然后根据 tokenType 计算签名并设置 Authorization 头。
+// 调用后不能再修改 req 的 Method、URL、Host、Content-Type、X-Qiniu-* 头以及表单或 JSON 请求体，否则签名会失效
+func SignRequest(mac *auth.Credentials, tokenType auth.TokenType, req *http.Request) error {
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	if !conf.IsDisableQiniuTimestampSignature() {
+		req.Header.Set(client.RequestHeaderKeyXQiniuDate, time.Now().UTC().Format("20060102T150405Z"))
+	}
+	req.Header.Del("Authorization")
+	return mac.AddToken(tokenType, req)
+}
@@ -0,0 +1,44 @@
+// +build unit
+
+package storage
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/qiniu/go-sdk/v7/auth"
+	"github.com/qiniu/go-sdk/v7/client"
+)
+
+func TestSignRequest(t *testing.T) {
+	mac := auth.New("ak", "sk")
+	req, err := http.NewRequest("POST", "http://rs.qiniu.com/stat/YnVja2V0OmtleQ==", strings.NewReader("a=b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// 重复签名只保留最后一次的 Authorization
+	for i := 0; i < 2; i++ {
+		if err = SignRequest(mac, auth.TokenQiniu, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(req.Header["Authorization"]) != 1 || !strings.HasPrefix(req.Header.Get("Authorization"), auth.AuthorizationPrefixQiniu+"ak:") {
+		t.Fatalf("unexpected authorization: %v", req.Header["Authorization"])
+	}
+	if req.Header.Get(client.RequestHeaderKeyXQiniuDate) == "" {
+		t.Fatal("X-Qiniu-Date should be set")
+	}
+	if ok, vErr := mac.VerifyCallback(req); vErr != nil || !ok {
+		t.Fatalf("signature verification failed: %v", vErr)
+	}
+
+	if err = SignRequest(mac, auth.TokenQBox, req); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(req.Header.Get("Authorization"), auth.AuthorizationPrefixQBox+"ak:") {
+		t.Fatalf("unexpected authorization: %v", req.Header.Get("Authorization"))
+	}
+}
@@ -0,0 +1,313 @@
+// Package testutil 提供了用于测试的七牛服务端模拟实现
+package testutil
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qiniu/go-sdk/v7/storage"
+)
+
+// MockQiniuServer 是一个在内存中保存文件信息的模拟服务，支持 rs/rsf 服务的 stat、delete、copy、move、list 和 batch 接口，
+// 返回的错误与七牛服务端的格式相同，如文件不存在时返回 612 {"error":"no such file or directory"}，
+// 目标文件已存在时返回 614 {"error":"file exists"}，空间不存在时返回 631 {"error":"no such bucket"}
+type MockQiniuServer struct {
+	*httptest.Server
+
+	// Config 所有的服务地址都指向该模拟服务，可以直接用于创建 BucketManager
+	Config *storage.Config
+
+	mu      sync.Mutex
+	buckets map[string]map[string]storage.FileInfo
+}
+
+// NewMockQiniuServer 创建并启动一个模拟服务，使用完成后需要调用 Close 关闭
+func NewMockQiniuServer() *MockQiniuServer {
+	s := &MockQiniuServer{
+		buckets: make(map[string]map[string]storage.FileInfo),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.Config = &storage.Config{
+		RsHost:        s.URL,
+		RsfHost:       s.URL,
+		ApiHost:       s.URL,
+		IoHost:        s.URL,
+		UcHost:        s.URL,
+		CentralRsHost: strings.TrimPrefix(s.URL, "http://"),
+	}
+	return s
+}
+
+// AddBucket 创建一个空的空间，空间已经存在时不做任何操作
+func (s *MockQiniuServer) AddBucket(bucket string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bucket(bucket, true)
+}
+
+// PutFile 保存文件信息，空间不存在时会自动创建，PutTime 为 0 时使用当前时间
+func (s *MockQiniuServer) PutFile(bucket, key string, info storage.FileInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if info.PutTime == 0 {
+		info.PutTime = time.Now().UnixNano() / 100
+	}
+	s.bucket(bucket, true)[key] = info
+}
+
+// File 返回保存的文件信息
+func (s *MockQiniuServer) File(bucket, key string) (info storage.FileInfo, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, ok = s.buckets[bucket][key]
+	return
+}
+
+func (s *MockQiniuServer) bucket(bucket string, create bool) map[string]storage.FileInfo {
+	files, ok := s.buckets[bucket]
+	if !ok && create {
+		files = make(map[string]storage.FileInfo)
+		s.buckets[bucket] = files
+	}
+	return files
+}
+
+type errorRet struct {
+	Error string `json:"error"`
+}
+
+// errorOf 返回七牛服务端的错误码 code 以及对应的错误信息，错误码和错误信息都与 storage 包一致
+func errorOf(code int) (int, errorRet) {
+	return code, errorRet{storage.ErrorCodeMessage(code)}
+}
+
+type batchRet struct {
+	Code int         `json:"code"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+func (s *MockQiniuServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/list":
+		s.list(w, r)
+	case "/v2/list":
+		s.listV2(w, r)
+	case "/batch":
+		if err := r.ParseForm(); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorRet{"invalid argument"})
+			return
+		}
+		rets := make([]batchRet, 0, len(r.PostForm["op"]))
+		for _, op := range r.PostForm["op"] {
+			code, data := s.do(op)
+			rets = append(rets, batchRet{Code: code, Data: data})
+		}
+		writeJSON(w, http.StatusOK, rets)
+	default:
+		code, data := s.do(r.URL.Path)
+		if data == nil {
+			w.WriteHeader(code)
+			return
+		}
+		writeJSON(w, code, data)
+	}
+}
+
+// do 执行单个操作，返回状态码和响应内容
+func (s *MockQiniuServer) do(op string) (code int, data interface{}) {
+	parts := strings.Split(strings.TrimPrefix(op, "/"), "/")
+	if len(parts) < 2 {
+		return http.StatusBadRequest, errorRet{"invalid argument"}
+	}
+	bucket, key, err := storage.DecodeEntry(parts[1])
+	if err != nil {
+		return http.StatusBadRequest, errorRet{"invalid argument"}
+	}
+	files := s.bucket(bucket, false)
+	if files == nil {
+		return errorOf(storage.ErrorCodeNoSuchBucket)
+	}
+	info, ok := files[key]
+
+	switch parts[0] {
+	case "stat":
+		if !ok {
+			return errorOf(storage.ErrorCodeNoSuchEntry)
+		}
+		return http.StatusOK, info
+	case "delete":
+		if !ok {
+			return errorOf(storage.ErrorCodeNoSuchEntry)
+		}
+		delete(files, key)
+		return http.StatusOK, nil
+	case "copy", "move":
+		if len(parts) < 3 {
+			return http.StatusBadRequest, errorRet{"invalid argument"}
+		}
+		destBucket, destKey, dErr := storage.DecodeEntry(parts[2])
+		if dErr != nil {
+			return http.StatusBadRequest, errorRet{"invalid argument"}
+		}
+		destFiles := s.bucket(destBucket, false)
+		if destFiles == nil {
+			return errorOf(storage.ErrorCodeNoSuchBucket)
+		}
+		if !ok {
+			return errorOf(storage.ErrorCodeNoSuchEntry)
+		}
+		force := len(parts) >= 5 && parts[3] == "force" && parts[4] == "true"
+		if _, exists := destFiles[destKey]; exists && !force {
+			return errorOf(storage.ErrorCodeEntryExists)
+		}
+		if parts[0] == "move" {
+			delete(files, key)
+		} else {
+			info.PutTime = time.Now().UnixNano() / 100
+		}
+		destFiles[destKey] = info
+		return http.StatusOK, nil
+	default:
+		return http.StatusNotFound, errorRet{"unsupported operation"}
+	}
+}
+
+type listRet struct {
+	Marker         string             `json:"marker,omitempty"`
+	Items          []storage.ListItem `json:"items"`
+	CommonPrefixes []string           `json:"commonPrefixes,omitempty"`
+}
+
+type listV2Ret struct {
+	Marker string            `json:"marker"`
+	Item   *storage.ListItem `json:"item,omitempty"`
+	Dir    string            `json:"dir,omitempty"`
+}
+
+// listEntry 列举结果中的一项，文件或者目录
+type listEntry struct {
+	name string
+	item *storage.ListItem
+}
+
+// entries 按照名称的字典序返回列举结果，marker 为上一次返回的最后一项的名称
+func (s *MockQiniuServer) entries(files map[string]storage.FileInfo, prefix, delimiter, marker string) []listEntry {
+	keys := make([]string, 0, len(files))
+	for key := range files {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	entries := make([]listEntry, 0, len(keys))
+	for _, key := range keys {
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				dir := key[:len(prefix)+i+len(delimiter)]
+				if len(entries) == 0 || entries[len(entries)-1].name != dir {
+					entries = append(entries, listEntry{name: dir})
+				}
+				continue
+			}
+		}
+		info := files[key]
+		entries = append(entries, listEntry{name: key, item: &storage.ListItem{
+			Key:      key,
+			Hash:     info.Hash,
+			Fsize:    info.Fsize,
+			PutTime:  info.PutTime,
+			MimeType: info.MimeType,
+			Type:     info.Type,
+			EndUser:  info.EndUser,
+		}})
+	}
+
+	if marker != "" {
+		for i, e := range entries {
+			if e.name > marker {
+				return entries[i:]
+			}
+		}
+		return nil
+	}
+	return entries
+}
+
+func (s *MockQiniuServer) listQuery(w http.ResponseWriter, r *http.Request) (files map[string]storage.FileInfo, marker string, ok bool) {
+	files = s.bucket(r.URL.Query().Get("bucket"), false)
+	if files == nil {
+		code, ret := errorOf(storage.ErrorCodeNoSuchBucket)
+		writeJSON(w, code, ret)
+		return nil, "", false
+	}
+	if m := r.URL.Query().Get("marker"); m != "" {
+		data, err := base64.URLEncoding.DecodeString(m)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorRet{"invalid marker"})
+			return nil, "", false
+		}
+		marker = string(data)
+	}
+	return files, marker, true
+}
+
+func (s *MockQiniuServer) list(w http.ResponseWriter, r *http.Request) {
+	files, marker, ok := s.listQuery(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	limit := 1000
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 && l < limit {
+		limit = l
+	}
+
+	entries := s.entries(files, query.Get("prefix"), query.Get("delimiter"), marker)
+	ret := listRet{Items: []storage.ListItem{}}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		ret.Marker = base64.URLEncoding.EncodeToString([]byte(entries[limit-1].name))
+	}
+	for _, e := range entries {
+		if e.item != nil {
+			ret.Items = append(ret.Items, *e.item)
+		} else {
+			ret.CommonPrefixes = append(ret.CommonPrefixes, e.name)
+		}
+	}
+	writeJSON(w, http.StatusOK, ret)
+}
+
+func (s *MockQiniuServer) listV2(w http.ResponseWriter, r *http.Request) {
+	files, marker, ok := s.listQuery(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	for _, e := range s.entries(files, query.Get("prefix"), query.Get("delimiter"), marker) {
+		ret := listV2Ret{Marker: base64.URLEncoding.EncodeToString([]byte(e.name)), Item: e.item}
+		if e.item == nil {
+			ret.Dir = e.name
+		}
+		enc.Encode(ret)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(data)
+}
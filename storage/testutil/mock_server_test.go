@@ -0,0 +1,76 @@
+// +build unit
+
+package testutil
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/qiniu/go-sdk/v7/auth"
+	"github.com/qiniu/go-sdk/v7/storage"
+)
+
+func TestMockQiniuServer(t *testing.T) {
+	srv := NewMockQiniuServer()
+	defer srv.Close()
+	srv.PutFile("bucket", "a", storage.FileInfo{Hash: "ha", Fsize: 1})
+	srv.PutFile("bucket", "dir/b", storage.FileInfo{Hash: "hb", Fsize: 2})
+	srv.PutFile("bucket", "dir/c", storage.FileInfo{Hash: "hc", Fsize: 3})
+	srv.AddBucket("empty")
+
+	m := storage.NewBucketManager(auth.New("ak", "sk"), srv.Config)
+
+	info, err := m.Stat("bucket", "a")
+	if err != nil || info.Hash != "ha" {
+		t.Fatalf("info = %+v, err = %v", info, err)
+	}
+	if _, err = m.Stat("bucket", "missing"); err == nil || err.(*storage.ErrorInfo).Code != 612 {
+		t.Fatalf("want 612, got %v", err)
+	}
+	if _, err = m.Stat("missing", "a"); err == nil || err.(*storage.ErrorInfo).Code != 631 {
+		t.Fatalf("want 631, got %v", err)
+	}
+	if err = m.Copy("bucket", "a", "bucket", "dir/b", false); err == nil || err.(*storage.ErrorInfo).Code != 614 {
+		t.Fatalf("want 614, got %v", err)
+	}
+	if err = m.Move("bucket", "a", "empty", "a", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := srv.File("bucket", "a"); ok {
+		t.Fatal("source file should be removed after move")
+	}
+
+	entries, prefixes, marker, hasNext, err := m.ListFiles("bucket", "", "/", "", 10)
+	if err != nil || len(entries) != 0 || strings.Join(prefixes, ",") != "dir/" || hasNext {
+		t.Fatalf("entries = %v, prefixes = %v, marker = %s, err = %v", entries, prefixes, marker, err)
+	}
+	entries, _, marker, hasNext, err = m.ListFiles("bucket", "dir/", "", "", 1)
+	if err != nil || len(entries) != 1 || entries[0].Key != "dir/b" || !hasNext {
+		t.Fatalf("entries = %v, marker = %s, err = %v", entries, marker, err)
+	}
+	entries, _, _, hasNext, err = m.ListFiles("bucket", "dir/", "", marker, 1)
+	if err != nil || len(entries) != 1 || entries[0].Key != "dir/c" || hasNext {
+		t.Fatalf("entries = %v, err = %v", entries, err)
+	}
+
+	retCh, err := m.ListFlat(context.Background(), "bucket", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for entry := range retCh {
+		if entry.Err != nil {
+			t.Fatal(entry.Err)
+		}
+		keys = append(keys, entry.Item.Key)
+	}
+	if strings.Join(keys, ",") != "dir/b,dir/c" {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+
+	rets, err := m.Batch([]string{storage.URIDelete("bucket", "dir/b"), storage.URIDelete("bucket", "missing")})
+	if err != nil || len(rets) != 2 || rets[0].Code != 200 || rets[1].Code != 612 || rets[1].Data.Error == "" {
+		t.Fatalf("rets = %+v, err = %v", rets, err)
+	}
+}
@@ -79,3 +79,26 @@ func TestGetAkBucketFromUploadToken(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestBuildUploadForm(t *testing.T) {
+	mac := auth.New("ak", "sk")
+	cfg := &Config{UseHTTPS: true, Region: &Region{SrcUpHosts: []string{"up.example.com"}}}
+	policy := &PutPolicy{Scope: "bucket:key"}
+	upHost, fields, err := buildUploadForm(cfg, mac, policy, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upHost != "https://up.example.com" || fields["key"] != "key" || len(fields) != 2 {
+		t.Fatalf("upHost = %s, fields = %v", upHost, fields)
+	}
+	if ak, bucket, err := getAkBucketFromUploadToken(fields["token"]); err != nil || ak != "ak" || bucket != "bucket" {
+		t.Fatalf("ak = %s, bucket = %s, err = %v", ak, bucket, err)
+	}
+
+	if _, fields, err = buildUploadForm(cfg, mac, policy, ""); err != nil || len(fields) != 1 {
+		t.Fatalf("fields = %v, err = %v", fields, err)
+	}
+	if _, _, err = buildUploadForm(cfg, mac, &PutPolicy{}, "key"); err == nil {
+		t.Fatal("expect error for empty scope")
+	}
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/qiniu/go-sdk/v7/client"
+)
+
+// Tracer 追踪接口，通过 Config.Tracer 设置后，资源管理的每次调用（包括重试）都会创建一个 span，
+// 可以通过实现该接口适配 OpenTelemetry 等追踪系统，SDK 本身不依赖任何追踪库
+type Tracer interface {
+	// Start 开始一个 span，operation 为操作名称，如 stat、move、batch、list，返回的 ctx 用于发送请求
+	Start(ctx context.Context, operation string) (context.Context, Span)
+}
+
+// Span 一次调用对应的 span
+type Span interface {
+	// SetAttribute 设置属性，SDK 会设置 operation、bucket、key（不为空时）、reqid 和 status（HTTP 状态码，网络错误时为 0）
+	SetAttribute(key string, value interface{})
+
+	// End 结束 span，err 为调用返回的错误
+	End(err error)
+}
+
+// operationName 返回请求路径中的操作名称，如 /stat/xxx 返回 stat
+func operationName(path string) string {
+	path = strings.TrimLeft(path, "/")
+	if i := strings.IndexAny(path, "/?"); i >= 0 {
+		path = path[:i]
+	}
+	return path
+}
+
+// call 发送资源管理请求，请求失败时按照 Config.RetryPolicy 重试，设置了 Config.Tracer 时为该调用创建 span
+func (m *BucketManager) call(ctx context.Context, operation, bucket, key string, call func(ctx context.Context) error) (err error) {
+	tracer := m.Cfg.Tracer
+	if tracer == nil {
+		return m.retry(ctx, func() error {
+			return call(ctx)
+		})
+	}
+
+	ctx, span := tracer.Start(ctx, operation)
+	var header http.Header
+	reqCtx := client.WithResponseHeader(ctx, &header)
+	err = m.retry(ctx, func() error {
+		return call(reqCtx)
+	})
+
+	status, reqid := http.StatusOK, header.Get("X-Reqid")
+	if err != nil {
+		status = 0
+		if errInfo, ok := err.(*ErrorInfo); ok {
+			status = errInfo.Code
+			if errInfo.Reqid != "" {
+				reqid = errInfo.Reqid
+			}
+		}
+	}
+	span.SetAttribute("operation", operation)
+	if bucket != "" {
+		span.SetAttribute("bucket", bucket)
+	}
+	if key != "" {
+		span.SetAttribute("key", key)
+	}
+	span.SetAttribute("reqid", reqid)
+	span.SetAttribute("status", status)
+	span.End(err)
+	return
+}
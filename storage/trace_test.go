@@ -0,0 +1,104 @@
+// +build unit
+
+package storage
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type testSpan struct {
+	operation string
+	attrs     map[string]interface{}
+	err       error
+	ended     bool
+}
+
+func (s *testSpan) SetAttribute(key string, value interface{}) {
+	s.attrs[key] = value
+}
+
+func (s *testSpan) End(err error) {
+	s.err, s.ended = err, true
+}
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, operation string) (context.Context, Span) {
+	span := &testSpan{operation: operation, attrs: make(map[string]interface{})}
+	t.spans = append(t.spans, span)
+	return ctx, span
+}
+
+func TestTracer(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/batch":
+			w.Header().Set("X-Reqid", "reqid-batch")
+			w.Write([]byte(`[{"code":200}]`))
+		default:
+			w.Header().Set("X-Reqid", "reqid-stat")
+			w.WriteHeader(612)
+			w.Write([]byte(`{"error":"no such file or directory"}`))
+		}
+	})
+	defer srv.Close()
+
+	if _, err := m.Stat("bucket", "key"); err == nil {
+		t.Fatal("expect error")
+	}
+	tracer := &testTracer{}
+	m.Cfg.Tracer = tracer
+	_, statErr := m.Stat("bucket", "key")
+	if _, err := m.Batch([]string{URIDelete("bucket", "key")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tracer.spans) != 2 {
+		t.Fatalf("want 2 spans, got %d", len(tracer.spans))
+	}
+	stat, batch := tracer.spans[0], tracer.spans[1]
+	if stat.operation != "stat" || !stat.ended || stat.err != statErr || stat.attrs["bucket"] != "bucket" || stat.attrs["key"] != "key" ||
+		stat.attrs["reqid"] != "reqid-stat" || stat.attrs["status"] != 612 {
+		t.Fatalf("unexpected stat span: %+v", stat)
+	}
+	if batch.operation != "batch" || batch.err != nil || batch.attrs["reqid"] != "reqid-batch" || batch.attrs["status"] != 200 {
+		t.Fatalf("unexpected batch span: %+v", batch)
+	}
+	if _, ok := batch.attrs["bucket"]; ok {
+		t.Fatalf("batch span should not have bucket: %+v", batch)
+	}
+}
+
+func TestAsyncFetchTraceAndRetry(t *testing.T) {
+	calls := 0
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if calls++; calls == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error":"too many requests"}`))
+			return
+		}
+		w.Write([]byte(`{"id":"job","wait":-1}`))
+	})
+	defer srv.Close()
+	tracer := &testTracer{}
+	m.Cfg.Tracer = tracer
+	m.Cfg.RetryPolicy = &RetryPolicy{MaxRetries: 1, BaseDelay: time.Millisecond}
+
+	if _, err := m.AsyncFetchRaw(context.Background(), "bucket", map[string]string{"url": "http://example.com/a", "bucket": "bucket"}); err != nil || calls != 2 {
+		t.Fatalf("calls = %d, err = %v", calls, err)
+	}
+	if _, err := m.AsyncFetchStatus(context.Background(), "bucket", "job"); err != nil {
+		t.Fatal(err)
+	}
+	if len(tracer.spans) != 2 || tracer.spans[0].operation != "asyncFetch" || tracer.spans[1].operation != "asyncFetchStatus" ||
+		tracer.spans[0].attrs["bucket"] != "bucket" || tracer.spans[0].attrs["status"] != 200 {
+		t.Fatalf("unexpected spans: %+v", tracer.spans)
+	}
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TreeOpts MoveTree、CopyTree 和 DeleteByPrefix 的可选参数
+type TreeOpts struct {
+	// Force 目标文件已经存在时是否覆盖，只用于 MoveTree 和 CopyTree
+	Force bool
+
+	// Progress 进度回调，每完成一批（最多 1000 个）操作后回调一次，done 为已经成功处理的文件数量，
+	// total 为需要处理的文件总数，列举完成前总数未知，此时 total 为 -1
+	Progress func(done, total int64)
+}
+
+// MoveTree 将 srcBucket 中以 srcPrefix 开头的所有文件移动到 destBucket 中，文件名的 srcPrefix 替换为 destPrefix，
+// 返回成功移动的文件数量，单个文件失败时不会中断，最后返回 KeyErrors（key 为源文件名）。
+// 同一个空间中目标前缀不能以源前缀开头，否则移动后的文件会被再次列举
+func (m *BucketManager) MoveTree(ctx context.Context, srcBucket, srcPrefix, destBucket, destPrefix string, opts *TreeOpts) (done int64, err error) {
+	if srcBucket == destBucket && strings.HasPrefix(destPrefix, srcPrefix) {
+		return 0, errors.New("destPrefix must not start with srcPrefix in the same bucket")
+	}
+	if opts == nil {
+		opts = &TreeOpts{}
+	}
+	return m.treeBatch(ctx, srcBucket, srcPrefix, opts, func(key string) string {
+		return URIMove(srcBucket, key, destBucket, destPrefix+strings.TrimPrefix(key, srcPrefix), opts.Force)
+	})
+}
+
+// CopyTree 将 srcBucket 中以 srcPrefix 开头的所有文件复制到 destBucket 中，文件名的 srcPrefix 替换为 destPrefix，
+// 返回成功复制的文件数量，单个文件失败时不会中断，最后返回 KeyErrors（key 为源文件名）。
+// 同一个空间中目标前缀不能以源前缀开头，否则复制后的文件会被再次列举
+func (m *BucketManager) CopyTree(ctx context.Context, srcBucket, srcPrefix, destBucket, destPrefix string, opts *TreeOpts) (done int64, err error) {
+	if srcBucket == destBucket && strings.HasPrefix(destPrefix, srcPrefix) {
+		return 0, errors.New("destPrefix must not start with srcPrefix in the same bucket")
+	}
+	if opts == nil {
+		opts = &TreeOpts{}
+	}
+	return m.treeBatch(ctx, srcBucket, srcPrefix, opts, func(key string) string {
+		return URICopy(srcBucket, key, destBucket, destPrefix+strings.TrimPrefix(key, srcPrefix), opts.Force)
+	})
+}
+
+// DeleteByPrefix 删除空间中以 prefix 开头的所有文件，返回成功删除的文件数量，单个文件失败时不会中断，最后返回 KeyErrors。
+// prefix 为空时会删除空间中的所有文件，清空空间请使用带有确认的 EmptyBucket
+func (m *BucketManager) DeleteByPrefix(ctx context.Context, bucket, prefix string, opts *TreeOpts) (done int64, err error) {
+	if prefix == "" {
+		return 0, errors.New("prefix is empty, use EmptyBucket to delete all files")
+	}
+	if opts == nil {
+		opts = &TreeOpts{}
+	}
+	return m.treeBatch(ctx, bucket, prefix, opts, func(key string) string {
+		return URIDelete(bucket, key)
+	})
+}
+
+// ReKey 按照 transform 重命名空间中以 prefix 开头的文件，transform 返回新的文件名，skip 为 true 或者新文件名与原文件名相同时跳过该文件。
+// 为了避免重命名后的文件被再次列举，会先列举完所有文件再通过 BatchAll 执行移动操作，最多同时发送 concurrency 批。
+// 返回成功重命名的文件数量，单个文件失败时不会中断，最后返回 KeyErrors（key 为原文件名），
+// 多个文件的新文件名相同时，只有第一个文件会被移动，其他文件记录为错误。
+// 新文件名不能是另一个需要重命名的文件的原文件名（如 a→b、b→c 或者 a、b 互换），
+// 此时并发执行的移动操作可能会丢失数据，ReKey 不执行任何操作并返回错误。
+// ctx 取消时返回 ctx.Err()，renamed 为取消前已经确认重命名成功的文件数量
+func (m *BucketManager) ReKey(ctx context.Context, bucket, prefix string, transform func(oldKey string) (newKey string, skip bool), force bool, concurrency int) (renamed int, err error) {
+	if transform == nil {
+		return 0, errors.New("transform is nil")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var (
+		keys    []string
+		ops     []string
+		keyErrs = make(KeyErrors)
+		targets = make(map[string]string)
+	)
+	err = m.WalkPrefix(ctx, bucket, prefix, nil, func(item ListItem) error {
+		newKey, skip := transform(item.Key)
+		if skip || newKey == item.Key {
+			return nil
+		}
+		if oldKey, ok := targets[newKey]; ok {
+			keyErrs[item.Key] = fmt.Errorf("new key %q is also the new key of %q", newKey, oldKey)
+			return nil
+		}
+		targets[newKey] = item.Key
+		keys = append(keys, item.Key)
+		ops = append(ops, URIMove(bucket, item.Key, bucket, newKey, force))
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	for _, key := range keys {
+		if oldKey, ok := targets[key]; ok {
+			return 0, fmt.Errorf("new key %q of %q is also renamed", key, oldKey)
+		}
+	}
+	// 部分批次已经完成时 BatchAll 也会返回错误，仍然需要统计已经返回的结果
+	rets, err := m.BatchAll(ctx, ops, concurrency)
+	for i, ret := range rets {
+		if ret.Code == 200 {
+			renamed++
+		} else {
+			keyErrs[keys[i]] = batchOpError(ret.Code, ret.Data.Error)
+		}
+	}
+	if err == nil && len(keyErrs) > 0 {
+		err = keyErrs
+	}
+	return
+}
+
+// treeBatch 列举空间中以 prefix 开头的文件，使用 op 为每个文件构建操作，并按照每批 1000 个操作执行
+func (m *BucketManager) treeBatch(ctx context.Context, bucket, prefix string, opts *TreeOpts, op func(key string) string) (done int64, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var (
+		listed  int64
+		keyErrs = make(KeyErrors)
+		keys    = make([]string, 0, batchLimit)
+		ops     = make([]string, 0, batchLimit)
+	)
+	flush := func(total int64) error {
+		if len(ops) > 0 {
+			var rets []BatchOpRet
+			if bErr := m.batch(ctx, ops, &rets); bErr != nil {
+				return bErr
+			}
+			if len(rets) != len(ops) {
+				return fmt.Errorf("batch returned %d results for %d operations", len(rets), len(ops))
+			}
+			for i, ret := range rets {
+				if ret.Code == 200 {
+					done++
+				} else {
+					keyErrs[keys[i]] = batchOpError(ret.Code, ret.Data.Error)
+				}
+			}
+			keys, ops = keys[:0], ops[:0]
+		}
+		if opts.Progress != nil {
+			opts.Progress(done, total)
+		}
+		return nil
+	}
+	err = m.WalkPrefix(ctx, bucket, prefix, nil, func(item ListItem) error {
+		listed++
+		keys, ops = append(keys, item.Key), append(ops, op(item.Key))
+		if len(ops) == batchLimit {
+			return flush(-1)
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush(listed)
+	}
+	if err == nil && len(keyErrs) > 0 {
+		err = keyErrs
+	}
+	return
+}
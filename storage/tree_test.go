@@ -0,0 +1,182 @@
+// +build unit
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMoveTree(t *testing.T) {
+	var items []string
+	for i := 0; i < 1200; i++ {
+		items = append(items, fmt.Sprintf(`{"key":"src/%04d"}`, i))
+	}
+	var ops []string
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/list":
+			if r.URL.Query().Get("prefix") != "src/" {
+				t.Errorf("unexpected query: %s", r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"items":[` + strings.Join(items, ",") + `]}`))
+		case "/batch":
+			r.ParseForm()
+			ops = append(ops, r.PostForm["op"]...)
+			rets := make([]string, 0, len(r.PostForm["op"]))
+			for _, op := range r.PostForm["op"] {
+				if op == URIMove("bucket", "src/0001", "bucket", "dest/0001", false) {
+					rets = append(rets, `{"code":614,"data":{"error":"file exists"}}`)
+					continue
+				}
+				rets = append(rets, `{"code":200}`)
+			}
+			w.Write([]byte("[" + strings.Join(rets, ",") + "]"))
+		}
+	})
+	defer srv.Close()
+
+	var progress []string
+	done, err := m.MoveTree(context.Background(), "bucket", "src/", "bucket", "dest/", &TreeOpts{
+		Progress: func(done, total int64) { progress = append(progress, fmt.Sprintf("%d/%d", done, total)) },
+	})
+	keyErrs, ok := err.(KeyErrors)
+	if !ok || len(keyErrs) != 1 || keyErrs["src/0001"] == nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if done != 1199 || len(ops) != 1200 || ops[1199] != URIMove("bucket", "src/1199", "bucket", "dest/1199", false) {
+		t.Fatalf("done = %d, ops = %d", done, len(ops))
+	}
+	if strings.Join(progress, ",") != "999/-1,1199/1200" {
+		t.Fatalf("unexpected progress: %v", progress)
+	}
+
+	if _, err = m.MoveTree(context.Background(), "bucket", "src/", "bucket", "src/sub/", nil); err == nil {
+		t.Fatal("expect error for nested prefix")
+	}
+	if _, err = m.DeleteByPrefix(context.Background(), "bucket", "", nil); err == nil {
+		t.Fatal("expect error for empty prefix")
+	}
+}
+
+func TestTreeBatchResultCount(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/list":
+			w.Write([]byte(`{"items":[{"key":"a/1"},{"key":"a/2"}]}`))
+		case "/batch":
+			w.Write([]byte(`[{"code":200}]`))
+		}
+	})
+	defer srv.Close()
+
+	done, err := m.DeleteByPrefix(context.Background(), "bucket", "a/", nil)
+	if _, ok := err.(KeyErrors); ok || err == nil || done != 0 {
+		t.Fatalf("done = %d, err = %v", done, err)
+	}
+}
+
+func TestReKey(t *testing.T) {
+	var ops []string
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/list":
+			w.Write([]byte(`{"items":[{"key":"img/A.JPG"},{"key":"img/b.jpg"},{"key":"img/C.png"},{"key":"img/a.jpg"},{"key":"img/D.JPG"},{"key":"img/E.jpg"},{"key":"img/e.JPG"}]}`))
+		case "/batch":
+			r.ParseForm()
+			ops = append(ops, r.PostForm["op"]...)
+			rets := make([]string, 0, len(r.PostForm["op"]))
+			for _, op := range r.PostForm["op"] {
+				if op == URIMove("bucket", "img/D.JPG", "bucket", "img/d.jpg", true) {
+					rets = append(rets, `{"code":612,"data":{"error":"no such file or directory"}}`)
+					continue
+				}
+				rets = append(rets, `{"code":200}`)
+			}
+			w.Write([]byte("[" + strings.Join(rets, ",") + "]"))
+		}
+	})
+	defer srv.Close()
+
+	renamed, err := m.ReKey(context.Background(), "bucket", "img/", func(oldKey string) (string, bool) {
+		return strings.ToLower(oldKey), strings.HasSuffix(oldKey, ".png")
+	}, true, 2)
+	keyErrs, ok := err.(KeyErrors)
+	if !ok || len(keyErrs) != 2 || keyErrs["img/D.JPG"] != ErrNoSuchEntry || keyErrs["img/e.JPG"] == nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if renamed != 2 || len(ops) != 3 || ops[0] != URIMove("bucket", "img/A.JPG", "bucket", "img/a.jpg", true) {
+		t.Fatalf("renamed = %d, ops = %v", renamed, ops)
+	}
+}
+
+func TestReKeyChain(t *testing.T) {
+	var batches int
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/list":
+			w.Write([]byte(`{"items":[{"key":"a"},{"key":"b"}]}`))
+		case "/batch":
+			batches++
+			w.Write([]byte(`[{"code":200},{"code":200}]`))
+		}
+	})
+	defer srv.Close()
+
+	next := map[string]string{"a": "b", "b": "c"}
+	if _, err := m.ReKey(context.Background(), "bucket", "", func(oldKey string) (string, bool) {
+		return next[oldKey], false
+	}, true, 1); err == nil || batches != 0 {
+		t.Fatalf("batches = %d, err = %v", batches, err)
+	}
+
+	swap := map[string]string{"a": "b", "b": "a"}
+	if _, err := m.ReKey(context.Background(), "bucket", "", func(oldKey string) (string, bool) {
+		return swap[oldKey], false
+	}, true, 1); err == nil || batches != 0 {
+		t.Fatalf("batches = %d, err = %v", batches, err)
+	}
+}
+
+func TestReKeyCanceled(t *testing.T) {
+	var items []string
+	for i := 0; i < 1500; i++ {
+		items = append(items, fmt.Sprintf(`{"key":"%04d"}`, i))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	batches := 0
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/list":
+			w.Write([]byte(`{"items":[` + strings.Join(items, ",") + `]}`))
+		case "/batch":
+			r.ParseForm()
+			if batches++; batches > 1 {
+				// 第一批已经完成，第二批发送时取消
+				cancel()
+			}
+			rets := make([]string, len(r.PostForm["op"]))
+			for i := range rets {
+				rets[i] = `{"code":200}`
+			}
+			w.Write([]byte("[" + strings.Join(rets, ",") + "]"))
+		}
+	})
+	defer srv.Close()
+
+	renamed, err := m.ReKey(ctx, "bucket", "", func(oldKey string) (string, bool) {
+		return "renamed/" + oldKey, false
+	}, false, 1)
+	if err != context.Canceled || renamed != 1000 {
+		t.Fatalf("renamed = %d, err = %v", renamed, err)
+	}
+}
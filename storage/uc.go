@@ -215,22 +215,67 @@ func (b *BucketInfo) TokenAntiLeechModeOn() bool {
 
 // GetBucketInfo 返回BucketInfo结构
 func (m *BucketManager) GetBucketInfo(bucketName string) (bucketInfo BucketInfo, err error) {
-	reqURL := fmt.Sprintf("%s/v2/bucketInfo?bucket=%s", getUcHost(m.Cfg.UseHTTPS), bucketName)
+	reqURL := fmt.Sprintf("%s/v2/bucketInfo?bucket=%s", m.Cfg.UcReqHost(), bucketName)
 	err = m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, &bucketInfo, "POST", reqURL, nil)
 	return
 }
 
 // BucketInfosForRegion 获取指定区域的该用户的所有bucketInfo信息
 func (m *BucketManager) BucketInfosInRegion(region RegionID, statistics bool) (bucketInfos []BucketSummary, err error) {
-	reqURL := fmt.Sprintf("%s/v2/bucketInfos?region=%s&fs=%t", getUcHost(m.Cfg.UseHTTPS), string(region), statistics)
+	reqURL := fmt.Sprintf("%s/v2/bucketInfos?region=%s&fs=%t", m.Cfg.centralUcReqHost(), string(region), statistics)
 	err = m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, &bucketInfos, "POST", reqURL, nil)
 	return
 }
 
 // SetReferAntiLeechMode 配置存储空间referer防盗链模式
 func (m *BucketManager) SetReferAntiLeechMode(bucketName string, refererAntiLeechConfig *ReferAntiLeechConfig) (err error) {
-	reqURL := fmt.Sprintf("%s/referAntiLeech?bucket=%s&%s", getUcHost(m.Cfg.UseHTTPS), bucketName, refererAntiLeechConfig.AsQueryString())
+	reqURL := fmt.Sprintf("%s/referAntiLeech?bucket=%s&%s", m.Cfg.UcReqHost(), bucketName, refererAntiLeechConfig.AsQueryString())
 	err = m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil)
+	return m.bucketInfoChanged(bucketName, err)
+}
+
+// RefererConfig 是存储空间的 Referer 防盗链配置
+type RefererConfig struct {
+	// 防盗链模式， 0 - 关闭Refer防盗链, 1 - 开启Referer白名单，2 - 开启Referer黑名单
+	Mode int
+
+	// 匹配HTTP Referer头的规则列表，当模式是1或者2的时候有效，规则格式参见 ReferAntiLeechConfig.Pattern
+	Pattern []string
+
+	// 是否允许空的referer访问
+	AllowEmptyReferer bool
+
+	// 是否开启源站的防盗链，在源站支持的情况下开启源站的Referer防盗链
+	EnableSource bool
+}
+
+// SetBucketReferer 配置存储空间的 Referer 防盗链白名单或黑名单
+func (m *BucketManager) SetBucketReferer(bucketName string, cfg RefererConfig) (err error) {
+	if cfg.Mode != 0 && cfg.Mode != 1 && cfg.Mode != 2 {
+		return fmt.Errorf("referer anti_leech_mode must be in [0, 1, 2], got %d", cfg.Mode)
+	}
+	return m.SetReferAntiLeechMode(bucketName, &ReferAntiLeechConfig{
+		Mode:              cfg.Mode,
+		AllowEmptyReferer: cfg.AllowEmptyReferer,
+		Pattern:           strings.Join(cfg.Pattern, ";"),
+		EnableSource:      cfg.EnableSource,
+	})
+}
+
+// GetBucketReferer 获取存储空间的 Referer 防盗链配置
+func (m *BucketManager) GetBucketReferer(bucketName string) (cfg RefererConfig, err error) {
+	bucketInfo, err := m.GetBucketInfo(bucketName)
+	if err != nil {
+		return
+	}
+	cfg.Mode = bucketInfo.AntiLeechMode
+	cfg.AllowEmptyReferer = bucketInfo.NoRefer
+	cfg.EnableSource = bucketInfo.EnableSource
+	if bucketInfo.WhiteListSet() {
+		cfg.Pattern = bucketInfo.ReferWl
+	} else if bucketInfo.BlackListSet() {
+		cfg.Pattern = bucketInfo.ReferBl
+	}
 	return
 }
 
@@ -281,7 +326,7 @@ func (m *BucketManager) AddBucketLifeCycleRule(bucketName string, lifeCycleRule
 	params["to_archive_after_days"] = []string{strconv.Itoa(lifeCycleRule.ToArchiveAfterDays)}
 	params["to_deep_archive_after_days"] = []string{strconv.Itoa(lifeCycleRule.ToDeepArchiveAfterDays)}
 
-	reqURL := getUcHost(m.Cfg.UseHTTPS) + "/rules/add"
+	reqURL := m.Cfg.UcReqHost() + "/rules/add"
 	err = m.Client.CredentialedCallWithForm(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil, params)
 	return
 
@@ -294,7 +339,7 @@ func (m *BucketManager) DelBucketLifeCycleRule(bucketName, ruleName string) (err
 	params["bucket"] = []string{bucketName}
 	params["name"] = []string{ruleName}
 
-	reqURL := getUcHost(m.Cfg.UseHTTPS) + "/rules/delete"
+	reqURL := m.Cfg.UcReqHost() + "/rules/delete"
 	err = m.Client.CredentialedCallWithForm(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil, params)
 	return
 }
@@ -311,14 +356,14 @@ func (m *BucketManager) UpdateBucketLifeCycleRule(bucketName string, rule *Bucke
 	params["to_archive_after_days"] = []string{strconv.Itoa(rule.ToArchiveAfterDays)}
 	params["to_deep_archive_after_days"] = []string{strconv.Itoa(rule.ToDeepArchiveAfterDays)}
 
-	reqURL := getUcHost(m.Cfg.UseHTTPS) + "/rules/update"
+	reqURL := m.Cfg.UcReqHost() + "/rules/update"
 	err = m.Client.CredentialedCallWithForm(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil, params)
 	return
 }
 
 // GetBucketLifeCycleRule 获取指定空间上设置的生命周期规则
 func (m *BucketManager) GetBucketLifeCycleRule(bucketName string) (rules []BucketLifeCycleRule, err error) {
-	reqURL := getUcHost(m.Cfg.UseHTTPS) + "/rules/get?bucket=" + bucketName
+	reqURL := m.Cfg.UcReqHost() + "/rules/get?bucket=" + bucketName
 	err = m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, &rules, "GET", reqURL, nil)
 	return
 }
@@ -377,7 +422,7 @@ func (r *BucketEventRule) Params(bucket string) map[string][]string {
 // AddBucketEvent 增加存储空间事件通知规则
 func (m *BucketManager) AddBucketEvent(bucket string, rule *BucketEventRule) (err error) {
 	params := rule.Params(bucket)
-	reqURL := getUcHost(m.Cfg.UseHTTPS) + "/events/add"
+	reqURL := m.Cfg.UcReqHost() + "/events/add"
 	err = m.Client.CredentialedCallWithForm(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil, params)
 	return
 }
@@ -388,7 +433,7 @@ func (m *BucketManager) DelBucketEvent(bucket, ruleName string) (err error) {
 	params["bucket"] = []string{bucket}
 	params["name"] = []string{ruleName}
 
-	reqURL := getUcHost(m.Cfg.UseHTTPS) + "/events/delete"
+	reqURL := m.Cfg.UcReqHost() + "/events/delete"
 	err = m.Client.CredentialedCallWithForm(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil, params)
 	return
 }
@@ -396,14 +441,14 @@ func (m *BucketManager) DelBucketEvent(bucket, ruleName string) (err error) {
 // UpdateBucketEnvent 更新指定存储空间的事件通知规则
 func (m *BucketManager) UpdateBucketEnvent(bucket string, rule *BucketEventRule) (err error) {
 	params := rule.Params(bucket)
-	reqURL := getUcHost(m.Cfg.UseHTTPS) + "/events/update"
+	reqURL := m.Cfg.UcReqHost() + "/events/update"
 	err = m.Client.CredentialedCallWithForm(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil, params)
 	return
 }
 
 // GetBucketEvent 获取指定存储空间的事件通知规则
 func (m *BucketManager) GetBucketEvent(bucket string) (rule []BucketEventRule, err error) {
-	reqURL := getUcHost(m.Cfg.UseHTTPS) + "/events/get?bucket=" + bucket
+	reqURL := m.Cfg.UcReqHost() + "/events/get?bucket=" + bucket
 	err = m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, &rule, "GET", reqURL, nil)
 	return
 }
@@ -439,14 +484,14 @@ type CorsRule struct {
 
 // AddCorsRules 设置指定存储空间的跨域规则
 func (m *BucketManager) AddCorsRules(bucket string, corsRules []CorsRule) (err error) {
-	reqURL := getUcHost(m.Cfg.UseHTTPS) + "/corsRules/set/" + bucket
+	reqURL := m.Cfg.UcReqHost() + "/corsRules/set/" + bucket
 	err = m.Client.CredentialedCallWithJson(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil, corsRules)
 	return
 }
 
 // GetCorsRules 获取指定存储空间的跨域规则
 func (m *BucketManager) GetCorsRules(bucket string) (corsRules []CorsRule, err error) {
-	reqURL := getUcHost(m.Cfg.UseHTTPS) + "/corsRules/get/" + bucket
+	reqURL := m.Cfg.UcReqHost() + "/corsRules/get/" + bucket
 	err = m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, &corsRules, "GET", reqURL, nil)
 	return
 }
@@ -495,8 +540,8 @@ func (m *BucketManager) GetBucketQuota(bucket string) (quota BucketQuota, err er
 // mode - 1 ==> 开启原图保护
 // mode - 0 ==> 关闭原图保护
 func (m *BucketManager) SetBucketAccessStyle(bucket string, mode int) error {
-	reqURL := fmt.Sprintf("%s/accessMode/%s/mode/%d", getUcHost(m.Cfg.UseHTTPS), bucket, mode)
-	return m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil)
+	reqURL := fmt.Sprintf("%s/accessMode/%s/mode/%d", m.Cfg.UcReqHost(), bucket, mode)
+	return m.bucketInfoChanged(bucket, m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil))
 }
 
 // TurnOffBucketProtected 开启指定存储空间的原图保护
@@ -512,8 +557,8 @@ func (m *BucketManager) TurnOffBucketProtected(bucket string) error {
 // SetBucketMaxAge 设置指定存储空间的MaxAge响应头
 // maxAge <= 0时，表示使用默认值31536000
 func (m *BucketManager) SetBucketMaxAge(bucket string, maxAge int64) error {
-	reqURL := fmt.Sprintf("%s/maxAge?bucket=%s&maxAge=%d", getUcHost(m.Cfg.UseHTTPS), bucket, maxAge)
-	return m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil)
+	reqURL := fmt.Sprintf("%s/maxAge?bucket=%s&maxAge=%d", m.Cfg.UcReqHost(), bucket, maxAge)
+	return m.bucketInfoChanged(bucket, m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil))
 }
 
 // SetBucketAccessMode 设置指定空间的私有属性
@@ -521,8 +566,8 @@ func (m *BucketManager) SetBucketMaxAge(bucket string, maxAge int64) error {
 // mode - 1 表示设置空间为私有空间， 私有空间访问需要鉴权
 // mode - 0 表示设置空间为公开空间
 func (m *BucketManager) SetBucketAccessMode(bucket string, mode int) error {
-	reqURL := fmt.Sprintf("%s/private?bucket=%s&private=%d", getUcHost(m.Cfg.UseHTTPS), bucket, mode)
-	return m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil)
+	reqURL := fmt.Sprintf("%s/private?bucket=%s&private=%d", m.Cfg.UcReqHost(), bucket, mode)
+	return m.bucketInfoChanged(bucket, m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil))
 }
 
 // MakeBucketPublic 设置空间为公有空间
@@ -535,6 +580,63 @@ func (m *BucketManager) MakeBucketPrivate(bucket string) error {
 	return m.SetBucketAccessMode(bucket, 1)
 }
 
+// AssertRegion 查询空间所在的区域，与 expected 不一致时返回 *RegionMismatchError，
+// 可以在批量删除等危险操作之前调用，避免因为空间名错误而操作其他区域的空间
+func (m *BucketManager) AssertRegion(bucket string, expected RegionID) error {
+	info, err := m.cachedBucketInfo(bucket)
+	if err != nil {
+		return err
+	}
+	actual := RegionID(info.Region)
+	if actual == "" {
+		actual = RegionID(info.Zone)
+	}
+	if actual == "" {
+		return fmt.Errorf("region of bucket %s is unknown", bucket)
+	}
+	if actual != expected {
+		return &RegionMismatchError{Bucket: bucket, Expected: expected, Actual: actual}
+	}
+	return nil
+}
+
+// IsPublic 判断空间中的文件是否可以不经过鉴权直接下载。七牛不支持文件级别的访问权限，
+// 文件的访问权限与所在空间相同：公开空间中的文件可以直接下载，私有空间需要使用私有链接；被禁用（Status 为 1）的文件不可访问
+func (m *BucketManager) IsPublic(bucket, key string) (public bool, err error) {
+	info, err := m.Stat(bucket, key)
+	if err != nil {
+		return
+	}
+	if info.Status == 1 {
+		return false, nil
+	}
+	bucketInfo, err := m.cachedBucketInfo(bucket)
+	if err != nil {
+		return
+	}
+	return !bucketInfo.IsPrivate(), nil
+}
+
+// URLBuilder 查询一次空间的访问权限，返回生成该空间中文件下载链接的函数：私有空间使用 m.Mac 签名，参见 MakePrivateURLv2；
+// 公开空间直接返回 MakePublicURLv2 生成的链接，忽略 deadline。文件名按照 Config.EscapePolicy 进行 escape。返回的函数会一直使用查询时的结果，
+// 可以被多个 goroutine 同时使用，空间的访问权限修改后需要重新调用 URLBuilder
+func (m *BucketManager) URLBuilder(bucket string) (build func(domain, key string, deadline int64) string, err error) {
+	info, err := m.cachedBucketInfo(bucket)
+	if err != nil {
+		return nil, err
+	}
+	policy := m.Cfg.EscapePolicy
+	if !info.IsPrivate() {
+		return func(domain, key string, deadline int64) string {
+			return MakePublicURLWithPolicy(domain, key, policy)
+		}, nil
+	}
+	mac := m.Mac
+	return func(domain, key string, deadline int64) string {
+		return MakePrivateURLWithPolicy(mac, domain, key, policy, deadline)
+	}, nil
+}
+
 // TurnOnIndexPage 设置默认首页
 func (m *BucketManager) TurnOnIndexPage(bucket string) error {
 	return m.setIndexPage(bucket, 0)
@@ -546,8 +648,8 @@ func (m *BucketManager) TurnOffIndexPage(bucket string) error {
 }
 
 func (m *BucketManager) setIndexPage(bucket string, noIndexPage int) error {
-	reqURL := fmt.Sprintf("%s/noIndexPage?bucket=%s&noIndexPage=%d", getUcHost(m.Cfg.UseHTTPS), bucket, noIndexPage)
-	return m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil)
+	reqURL := fmt.Sprintf("%s/noIndexPage?bucket=%s&noIndexPage=%d", m.Cfg.UcReqHost(), bucket, noIndexPage)
+	return m.bucketInfoChanged(bucket, m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, nil, "POST", reqURL, nil))
 }
 
 // BucketTagging 为 Bucket 设置标签
@@ -570,20 +672,20 @@ func (m *BucketManager) SetTagging(bucket string, tags map[string]string) error
 		tagging.Tags = append(tagging.Tags, BucketTag{Key: key, Value: value})
 	}
 
-	reqURL := fmt.Sprintf("%s/bucketTagging?bucket=%s", getUcHost(m.Cfg.UseHTTPS), bucket)
+	reqURL := fmt.Sprintf("%s/bucketTagging?bucket=%s", m.Cfg.UcReqHost(), bucket)
 	return m.Client.CredentialedCallWithJson(context.Background(), m.Mac, auth.TokenQiniu, nil, "PUT", reqURL, nil, &tagging)
 }
 
 // ClearTagging 清空 Bucket 标签
 func (m *BucketManager) ClearTagging(bucket string) error {
-	reqURL := fmt.Sprintf("%s/bucketTagging?bucket=%s", getUcHost(m.Cfg.UseHTTPS), bucket)
+	reqURL := fmt.Sprintf("%s/bucketTagging?bucket=%s", m.Cfg.UcReqHost(), bucket)
 	return m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, nil, "DELETE", reqURL, nil)
 }
 
 // GetTagging 获取 Bucket 标签
 func (m *BucketManager) GetTagging(bucket string) (tags map[string]string, err error) {
 	var tagging BucketTagging
-	reqURL := fmt.Sprintf("%s/bucketTagging?bucket=%s", getUcHost(m.Cfg.UseHTTPS), bucket)
+	reqURL := fmt.Sprintf("%s/bucketTagging?bucket=%s", m.Cfg.UcReqHost(), bucket)
 	if err = m.Client.CredentialedCall(context.Background(), m.Mac, auth.TokenQiniu, &tagging, "GET", reqURL, nil); err != nil {
 		return
 	}
@@ -5,6 +5,9 @@ package storage
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -34,3 +37,166 @@ func TestUcBucketEventRule(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestBucketReferer(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/referAntiLeech":
+			q := r.URL.Query()
+			if q.Get("bucket") != "bucket" || q.Get("mode") != "1" || q.Get("pattern") != "foo.com;*.bar.com" ||
+				q.Get("norefer") != "1" || q.Get("source_enabled") != "0" {
+				t.Errorf("unexpected query: %s", r.URL.RawQuery)
+			}
+		case "/v2/bucketInfo":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"anti_leech_mode":1,"refer_wl":["foo.com","*.bar.com"],"refer_bl":["baz.com"],"no_refer":true}`))
+		default:
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+	})
+	defer srv.Close()
+
+	cfg := RefererConfig{Mode: 1, Pattern: []string{"foo.com", "*.bar.com"}, AllowEmptyReferer: true}
+	if err := m.SetBucketReferer("bucket", cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetBucketReferer("bucket", RefererConfig{Mode: 3}); err == nil {
+		t.Fatal("expect error for invalid mode")
+	}
+
+	got, err := m.GetBucketReferer("bucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, cfg) {
+		t.Fatalf("want = %+v, got = %+v", cfg, got)
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	private, status := 0, 0
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/stat/"):
+			fmt.Fprintf(w, `{"hash":"h","status":%d}`, status)
+		case r.URL.Path == "/v2/bucketInfo":
+			fmt.Fprintf(w, `{"private":%d}`, private)
+		default:
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+	})
+	defer srv.Close()
+
+	for _, c := range []struct {
+		private, status int
+		want            bool
+	}{{0, 0, true}, {1, 0, false}, {0, 1, false}} {
+		private, status = c.private, c.status
+		if public, err := m.IsPublic("bucket", "key"); err != nil || public != c.want {
+			t.Fatalf("private = %d, status = %d: public = %v, err = %v", c.private, c.status, public, err)
+		}
+	}
+}
+
+func TestAssertRegion(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("bucket") {
+		case "z0-bucket":
+			w.Write([]byte(`{"region":"z0","zone":"z0"}`))
+		case "legacy":
+			w.Write([]byte(`{"zone":"z1"}`))
+		default:
+			w.WriteHeader(631)
+			w.Write([]byte(`{"error":"no such bucket"}`))
+		}
+	})
+	defer srv.Close()
+
+	if err := m.AssertRegion("z0-bucket", RIDHuadong); err != nil {
+		t.Fatal(err)
+	}
+	err := m.AssertRegion("z0-bucket", RIDHuanan)
+	if e, ok := err.(*RegionMismatchError); !ok || e.Actual != RIDHuadong || e.Expected != RIDHuanan {
+		t.Fatalf("want *RegionMismatchError, got %v", err)
+	}
+	if err = m.AssertRegion("legacy", RIDHuabei); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.AssertRegion("missing", RIDHuadong); !IsNoSuchBucket(err) {
+		t.Fatalf("want 631, got %v", err)
+	}
+}
+
+func TestURLBuilder(t *testing.T) {
+	calls := 0
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("bucket") {
+		case "private":
+			w.Write([]byte(`{"private":1}`))
+		case "public":
+			w.Write([]byte(`{"private":0}`))
+		default:
+			w.WriteHeader(631)
+			w.Write([]byte(`{"error":"no such bucket"}`))
+		}
+	})
+	defer srv.Close()
+
+	build, err := m.URLBuilder("private")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if u := build("https://cdn.example.com", "a b", 1609459200); u != MakePrivateURLv2(m.Mac, "https://cdn.example.com", "a b", 1609459200) {
+			t.Fatalf("unexpected private url: %s", u)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("bucket info should be queried once, got %d", calls)
+	}
+
+	if build, err = m.URLBuilder("public"); err != nil {
+		t.Fatal(err)
+	}
+	if u := build("https://cdn.example.com", "a b", 1609459200); u != MakePublicURLv2("https://cdn.example.com", "a b") {
+		t.Fatalf("unexpected public url: %s", u)
+	}
+	if _, err = m.URLBuilder("missing"); !IsNoSuchBucket(err) {
+		t.Fatalf("want 631, got %v", err)
+	}
+}
+
+func TestUcErrorEnvelope(t *testing.T) {
+	var code int
+	var body string
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(code)
+		w.Write([]byte(body))
+	})
+	defer srv.Close()
+
+	for _, c := range []struct {
+		code              int
+		body              string
+		wantErr, wantCode string
+	}{
+		{400, `{"error_code":"BadRequest","error":"invalid bucket name"}`, "invalid bucket name", "BadRequest"},
+		{631, `{"error_code":631}`, "631", "631"},
+		{631, `{"error":"no such bucket"}`, "no such bucket", ""},
+	} {
+		code, body = c.code, c.body
+		_, err := m.GetBucketInfo("bucket")
+		errInfo, ok := err.(*ErrorInfo)
+		if !ok || errInfo.Code != c.code || errInfo.Err != c.wantErr || errInfo.ErrorCode != c.wantCode {
+			t.Fatalf("body = %s: err = %#v", c.body, err)
+		}
+		if err = m.AssertRegion("bucket", "z0"); IsNoSuchBucket(err) != (c.code == 631) {
+			t.Fatalf("body = %s: AssertRegion err = %v", c.body, err)
+		}
+	}
+}
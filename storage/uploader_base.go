@@ -1,10 +1,17 @@
 package storage
 
-import "github.com/qiniu/go-sdk/v7/internal/hostprovider"
+import (
+	"fmt"
+
+	"github.com/qiniu/go-sdk/v7/internal/hostprovider"
+)
 
 func getUpHost(config *Config, ak, bucket string) (upHost string, err error) {
 	region := config.GetRegion()
 	if region == nil {
+		if config.DisableZoneDiscovery {
+			return "", fmt.Errorf("%s, Config.Region is required for upload", ErrZoneDiscoveryDisabled)
+		}
 		if region, err = GetRegion(ak, bucket); err != nil {
 			return "", err
 		}
@@ -23,6 +30,9 @@ func getUpHostProvider(config *Config, ak, bucket string) (hostprovider.HostProv
 	region := config.GetRegion()
 	var err error
 	if region == nil {
+		if config.DisableZoneDiscovery {
+			return nil, fmt.Errorf("%s, Config.Region is required for upload", ErrZoneDiscoveryDisabled)
+		}
 		if region, err = GetRegion(ak, bucket); err != nil {
 			return nil, err
 		}
@@ -58,7 +58,7 @@ func isContextExpiredError(err error) bool {
 		return false
 	}
 
-	return errInfo.Code == 701 || (errInfo.Code == 612 && strings.Contains(errInfo.Error(), "no such uploadId"))
+	return errInfo.Code == ErrorCodeInvalidContext || (errInfo.Code == ErrorCodeNoSuchEntry && strings.Contains(errInfo.Error(), "no such uploadId"))
 }
 
 func shouldUploadRetryWithOtherHost(err error) bool {
@@ -75,7 +75,7 @@ func shouldUploadRetryWithOtherHost(err error) bool {
 		return true
 	}
 
-	return errInfo.Code > 499 && errInfo.Code < 600 && errInfo.Code != 573 && errInfo.Code != 579
+	return errInfo.Code > 499 && errInfo.Code < 600 && errInfo.Code != ErrorCodeTooManyRequests && errInfo.Code != ErrorCodeCallbackFailed
 }
 
 func doUploadAction(hostProvider hostprovider.HostProvider, retryMax int, freezeDuration time.Duration, action func(host string) error) error {
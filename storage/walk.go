@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// SkipRemaining 在 WalkPrefix 的回调中返回该错误，表示不再处理剩余的文件，WalkPrefix 会正常结束并返回 nil
+var SkipRemaining = errors.New("skip remaining entries")
+
+// walkPageSize WalkPrefix 每页列举的文件数量
+const walkPageSize = 1000
+
+// WalkState 记录 WalkPrefix 的进度，可以持久化后用于中断后继续遍历
+type WalkState struct {
+	// Marker 下一页的列举位置，每处理完一页后更新
+	Marker string `json:"marker"`
+
+	// Done 为 true 表示已经遍历完成
+	Done bool `json:"done"`
+}
+
+// WalkPrefix 按页遍历空间中以 prefix 开头的文件，并对每个文件调用 fn
+// checkpoint 不为 nil 时从 checkpoint.Marker 处开始遍历，并在每处理完一页后更新 checkpoint，
+// 遇到 ctx 取消或 fn 返回错误时立即停止并返回该错误，此时 checkpoint 指向未处理完的页的开始位置，
+// 使用同一个 checkpoint 再次调用即可继续遍历，因此该页中已经处理过的文件可能会被再次处理。
+// fn 返回 SkipRemaining 时停止遍历并返回 nil
+func (m *BucketManager) WalkPrefix(ctx context.Context, bucket, prefix string, checkpoint *WalkState, fn func(ListItem) error) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if checkpoint == nil {
+		checkpoint = &WalkState{}
+	}
+	for !checkpoint.Done {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		entries, _, nextMarker, hasNext, err := m.listFiles(ctx, bucket, prefix, "", checkpoint.Marker, walkPageSize)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err = ctx.Err(); err != nil {
+				return err
+			}
+			if err = fn(entry); err == SkipRemaining {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+		checkpoint.Marker = nextMarker
+		checkpoint.Done = !hasNext
+	}
+	return nil
+}
+
+// ListFilesMatch 遍历空间中以 prefix 开头的文件，只对匹配 include 中任意一个模式（include 为空时匹配所有文件），
+// 并且不匹配 exclude 中任何模式的文件调用 fn，fn 返回错误时停止遍历并返回该错误（SkipRemaining 除外）。
+// 模式使用 path.Match 的语法，不包含 "/" 的模式匹配文件名的最后一段，如 "*.jpg"；
+// 包含 "/" 的模式匹配去掉 prefix 后的文件名，如 "thumbnails/*.jpg"；以 "/" 结尾的模式匹配该目录下的所有文件，如 "thumbnails/"
+func (m *BucketManager) ListFilesMatch(ctx context.Context, bucket, prefix string, include, exclude []string, fn func(ListItem) error) error {
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %s", pattern, err)
+		}
+	}
+	return m.WalkPrefix(ctx, bucket, prefix, nil, func(item ListItem) error {
+		rel := strings.TrimPrefix(item.Key, prefix)
+		if (len(include) > 0 && !matchAny(include, rel)) || matchAny(exclude, rel) {
+			return nil
+		}
+		return fn(item)
+	})
+}
+
+// matchAny 判断去掉前缀后的文件名 rel 是否匹配 patterns 中的任意一个模式，模式的规则参见 ListFilesMatch
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		var matched bool
+		switch {
+		case strings.HasSuffix(pattern, "/"):
+			matched = strings.HasPrefix(rel, pattern)
+		case strings.Contains(pattern, "/"):
+			matched, _ = path.Match(pattern, rel)
+		default:
+			matched, _ = path.Match(pattern, path.Base(rel))
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
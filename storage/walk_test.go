@@ -0,0 +1,116 @@
+// +build unit
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func newWalkMockBucketManager(t *testing.T) (*BucketManager, func()) {
+	pages := map[string]string{
+		"":   `{"marker":"m1","items":[{"key":"a"},{"key":"b"}]}`,
+		"m1": `{"marker":"","items":[{"key":"c"}]}`,
+	}
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Query().Get("marker")]
+		if !ok {
+			t.Errorf("unexpected marker: %s", r.URL.Query().Get("marker"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(page))
+	})
+	return m, srv.Close
+}
+
+func TestWalkPrefix(t *testing.T) {
+	m, closeFn := newWalkMockBucketManager(t)
+	defer closeFn()
+
+	var keys []string
+	state := &WalkState{}
+	errStop := errors.New("stop")
+	err := m.WalkPrefix(context.Background(), "bucket", "", state, func(item ListItem) error {
+		if item.Key == "c" {
+			return errStop
+		}
+		keys = append(keys, item.Key)
+		return nil
+	})
+	if err != errStop || state.Marker != "m1" || state.Done {
+		t.Fatalf("err = %v, state = %+v", err, state)
+	}
+
+	// 从 checkpoint 继续遍历
+	err = m.WalkPrefix(context.Background(), "bucket", "", state, func(item ListItem) error {
+		keys = append(keys, item.Key)
+		return nil
+	})
+	if err != nil || !state.Done {
+		t.Fatalf("err = %v, state = %+v", err, state)
+	}
+	if fmt.Sprint(keys) != "[a b c]" {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+
+	// 已完成的遍历不会再发送请求
+	if err = m.WalkPrefix(context.Background(), "bucket", "", state, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	keys = nil
+	err = m.WalkPrefix(context.Background(), "bucket", "", nil, func(item ListItem) error {
+		keys = append(keys, item.Key)
+		return SkipRemaining
+	})
+	if err != nil || fmt.Sprint(keys) != "[a]" {
+		t.Fatalf("err = %v, keys = %v", err, keys)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err = m.WalkPrefix(ctx, "bucket", "", nil, nil); err != context.Canceled {
+		t.Fatalf("want context.Canceled, got %v", err)
+	}
+}
+
+func TestListFilesMatch(t *testing.T) {
+	m, srv := newMockBucketManager(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"items":[
+			{"key":"photos/a.jpg"},
+			{"key":"photos/b.png"},
+			{"key":"photos/2021/c.JPG"},
+			{"key":"photos/2021/d.jpg"},
+			{"key":"photos/thumbnails/a.jpg"},
+			{"key":"photos/thumbnails/x/b.jpg"}
+		]}`))
+	})
+	defer srv.Close()
+
+	var keys []string
+	err := m.ListFilesMatch(context.Background(), "bucket", "photos/", []string{"*.jpg", "*.JPG"}, []string{"thumbnails/"}, func(item ListItem) error {
+		keys = append(keys, item.Key)
+		return nil
+	})
+	if err != nil || strings.Join(keys, ",") != "photos/a.jpg,photos/2021/c.JPG,photos/2021/d.jpg" {
+		t.Fatalf("keys = %v, err = %v", keys, err)
+	}
+
+	keys = nil
+	err = m.ListFilesMatch(context.Background(), "bucket", "photos/", []string{"2021/*"}, nil, func(item ListItem) error {
+		keys = append(keys, item.Key)
+		return SkipRemaining
+	})
+	if err != nil || strings.Join(keys, ",") != "photos/2021/c.JPG" {
+		t.Fatalf("keys = %v, err = %v", keys, err)
+	}
+
+	if err = m.ListFilesMatch(context.Background(), "bucket", "", []string{"[a-"}, nil, func(ListItem) error { return nil }); err == nil {
+		t.Fatal("expect error for bad pattern")
+	}
+}